@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/service"
+)
+
+var activityTable = regexp.MustCompile(`^activity_logs(_\d{4}_\d{2})?$`)
+
+// activityCmd maintains the activity log:
+//
+//	export     write activity_logs, or one monthly partition, as NDJSON
+//	retention  run one partition maintenance pass: create upcoming monthly
+//	           partitions and archive those past the retention period to
+//	           gzipped NDJSON before dropping them
+func activityCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	var run func(ctx context.Context, database *db.Database) error
+	switch sub {
+	case "export":
+		fs := flag.NewFlagSet("activity export", flag.ExitOnError)
+		table := fs.String("table", "activity_logs", "table or partition to export, e.g. activity_logs_2026_01")
+		out := fs.String("o", "", "output file (default stdout)")
+		_ = fs.Parse(args)
+		if fs.NArg() > 0 || !activityTable.MatchString(*table) {
+			return errUsage
+		}
+		run = func(ctx context.Context, database *db.Database) error {
+			return exportActivity(ctx, database, *table, *out)
+		}
+	case "retention":
+		fs := flag.NewFlagSet("activity retention", flag.ExitOnError)
+		dryRun := fs.Bool("dry-run", false, "only list the partitions that would be archived")
+		fs.IntVar(&cfg.ActivityRetentionMonths, "retention-months", cfg.ActivityRetentionMonths, "months of activity to keep (0 keeps everything)")
+		fs.StringVar(&cfg.ActivityArchiveDir, "archive-dir", cfg.ActivityArchiveDir, "directory for the exported .ndjson.gz files")
+		_ = fs.Parse(args)
+		if fs.NArg() > 0 {
+			return errUsage
+		}
+		run = func(ctx context.Context, database *db.Database) error {
+			rep, err := service.NewActivityRetention(cfg, database).RunOnce(ctx, time.Now(), *dryRun)
+			if err != nil {
+				return err
+			}
+			if rep.Skipped {
+				slog.Warn("another instance is running maintenance; nothing done")
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(rep)
+		}
+	default:
+		return errUsage
+	}
+
+	database, err := db.Connect(cfg)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+	if err := requireSchema(ctx, database); err != nil {
+		return err
+	}
+	return run(ctx, database)
+}
+
+func exportActivity(ctx context.Context, database *db.Database, table, out string) error {
+	if out == "" {
+		return service.ExportActivity(ctx, database, table, os.Stdout)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := service.ExportActivity(ctx, database, table, f); err != nil {
+		f.Close()
+		return fmt.Errorf("export %s: %w", table, err)
+	}
+	return f.Close()
+}
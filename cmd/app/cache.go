@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+)
+
+// cacheCmd deletes cache keys by prefix. Purging generation counters
+// (gen:...) resets them to 0 and can resurrect stale results, so purge the
+// results they version together with them.
+func cacheCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "purge" {
+		return errUsage
+	}
+	fs := flag.NewFlagSet("cache purge", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "delete keys starting with this (required)")
+	_ = fs.Parse(args[1:])
+	if fs.NArg() > 0 || *prefix == "" {
+		return errUsage
+	}
+
+	c, err := cache.New(cfg)
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	defer c.Close()
+
+	n, err := c.Purge(ctx, *prefix)
+	fmt.Printf("purged %d keys\n", n)
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+// configCmd prints the effective configuration in .env format, with where
+// each value came from. -redact hides passwords and keys.
+func configCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errUsage
+	}
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	redact := fs.Bool("redact", false, "replace secret values with <redacted>")
+	_ = fs.Parse(args[1:])
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range cfg.Settings() {
+		v := s.Value
+		if *redact && s.Secret && v != "" {
+			v = "<redacted>"
+		}
+		if strings.ContainsAny(v, " \t#\"'\\$") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(w, "%s=%s\t# %s\n", s.Key, v, s.Source)
+	}
+	return w.Flush()
+}
@@ -1,52 +1,101 @@
+// Command app is the blog service and its operations tooling:
+//
+//	app [serve]                          run the HTTP API and background workers
+//	app migrate up|down|status|create    manage the database schema
+//	app reindex [-batch N]               rebuild the search index from Postgres
+//	app seed [-n N] [-rand-seed S]       create fake posts for development
+//	app cache purge -prefix P            delete cache keys starting with P
+//	app activity export|retention        dump or maintain activity_logs
+//	app user create-admin -id ID         allow an X-Actor-ID to write
+//	app config print [-redact]           show the effective configuration
+//
+// Every subcommand reads the same configuration (environment, .env and
+// CONFIG_FILE) and refuses to start if any of it is invalid, logs through
+// slog as set by LOG_LEVEL and LOG_FORMAT, and stops cleanly on SIGINT or
+// SIGTERM.
 package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/joho/godotenv"
 
-	"github.com/example/blog-service/internal/app"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/logging"
 )
 
-func main() {
-	_ = godotenv.Load()
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, cfg *config.Config, args []string) error
+}
 
-	application, err := app.Initialize()
-	if err != nil {
-		log.Fatalf("failed to initialize app: %v", err)
-	}
+var commands = []command{
+	{"serve", "serve", serveCmd},
+	{"migrate", "migrate up | down [-steps N] | status | create [-dir DIR] NAME", migrateCmd},
+	{"reindex", "reindex [-batch N]", reindexCmd},
+	{"seed", "seed [-n N] [-rand-seed S]", seedCmd},
+	{"cache", "cache purge -prefix PREFIX", cacheCmd},
+	{"activity", "activity export [-table T] [-o FILE] | retention [-dry-run] [-retention-months N] [-archive-dir DIR]", activityCmd},
+	{"user", "user create-admin -id ACTOR_ID [-name NAME]", userCmd},
+	{"config", "config print [-redact]", configCmd},
+}
+
+// errUsage makes main print the usage of the failing subcommand.
+var errUsage = errors.New("usage")
 
-	srv := &http.Server{
-		Addr:         ":" + application.Config.Port,
-		Handler:      application.Router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+func usage(cmds ...command) {
+	if len(cmds) == 0 {
+		cmds = commands
 	}
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, c := range cmds {
+		fmt.Fprintf(os.Stderr, "  app %s\n", c.usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	_ = godotenv.Load()
 
-	go func() {
-		log.Printf("HTTP server listening on :%s", application.Config.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
 		}
-	}()
+	}
+	if cmd == nil {
+		usage()
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
-	log.Println("shutdown signal received")
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := logging.Setup(cfg); err != nil {
+		log.Fatal(err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("server shutdown error: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = cmd.run(ctx, cfg, args)
+	stop()
+	if errors.Is(err, errUsage) {
+		usage(*cmd)
+	}
+	if err != nil {
+		slog.Error(name+" failed", "error", err)
+		os.Exit(1)
 	}
-	application.Close()
-	log.Println("server gracefully stopped")
 } 
\ No newline at end of file
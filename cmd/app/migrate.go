@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+)
+
+// migrateCmd manages the database schema:
+//
+//	up               apply every pending migration
+//	down [-steps N]  revert the latest N migrations (default 1)
+//	status           list migrations and when they were applied
+//	create NAME      add empty up/down files for a new migration
+func migrateCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	if sub == "create" {
+		fs := flag.NewFlagSet("migrate create", flag.ExitOnError)
+		dir := fs.String("dir", db.MigrationsDir, "migrations directory")
+		_ = fs.Parse(args)
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		up, down, err := db.CreateMigration(*dir, fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("create migration: %w", err)
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return nil
+	}
+
+	steps := 1
+	switch sub {
+	case "up", "status":
+		if len(args) > 0 {
+			return errUsage
+		}
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		fs.IntVar(&steps, "steps", 1, "number of migrations to revert")
+		_ = fs.Parse(args)
+		if fs.NArg() > 0 || steps < 1 {
+			return errUsage
+		}
+	default:
+		return errUsage
+	}
+
+	database, err := db.Connect(cfg)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+
+	switch sub {
+	case "up":
+		applied, err := database.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		reverted, err := database.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		status, err := database.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, st := range status {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			name := st.Name
+			if name == "" {
+				name = "(unknown to this build)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, name, applied)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// requireSchema fails if database has pending migrations; commands that
+// touch tables without going through app.Initialize call it first.
+func requireSchema(ctx context.Context, database *db.Database) error {
+	pending, err := database.PendingMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("db migration status: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind (%d pending migrations); run app migrate up first", len(pending))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/example/blog-service/internal/app"
+	"github.com/example/blog-service/internal/config"
+)
+
+// reindexCmd rewrites every post into the search index from Postgres, e.g.
+// after the index was recreated or fell behind.
+func reindexCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	batch := fs.Int("batch", 500, "posts read from Postgres per batch")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 || *batch < 1 {
+		return errUsage
+	}
+
+	application, err := app.Initialize(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize app: %w", err)
+	}
+	defer application.Close()
+
+	start := time.Now()
+	n, err := application.Services.Posts.Reindex(ctx, *batch, func(indexed int) {
+		slog.Info("reindex progress", "indexed", indexed)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("reindexed posts", "posts", n, "duration", time.Since(start).Round(time.Millisecond).String())
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/example/blog-service/internal/activity"
+	"github.com/example/blog-service/internal/app"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/seed"
+)
+
+// seedCmd creates fake posts through PostService, so they are indexed, cached
+// and logged like posts created over the API.
+func seedCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	n := fs.Int("n", 50, "number of posts to create")
+	randSeed := fs.Int64("rand-seed", time.Now().UnixNano(), "seed for the generator; the same seed yields the same posts")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 || *n < 1 {
+		return errUsage
+	}
+
+	application, err := app.Initialize(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize app: %w", err)
+	}
+	defer application.Close()
+
+	ctx = activity.WithActor(ctx, activity.Actor{ID: "seed"})
+	gen := seed.NewGenerator(*randSeed)
+	for i := 1; i <= *n; i++ {
+		p, err := application.Services.Posts.CreatePost(ctx, gen.Post())
+		if err != nil {
+			return fmt.Errorf("post %d of %d: %w", i, *n, err)
+		}
+		fmt.Printf("%d\t%s\n", p.ID, p.Slug)
+	}
+	slog.Info("created posts", "posts", *n, "rand_seed", *randSeed)
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/example/blog-service/internal/app"
+	"github.com/example/blog-service/internal/config"
+)
+
+// serveCmd runs the HTTP API and the background workers until ctx is done,
+// then drains and shuts down gracefully.
+func serveCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+
+	application, err := app.Initialize(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize app: %w", err)
+	}
+
+	srv := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      application.Router,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	application.StartWorkers(context.Background())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("HTTP server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received")
+	case err := <-serveErr:
+		application.Close()
+		return fmt.Errorf("server error: %w", err)
+	}
+
+	// fail /readyz first, so load balancers stop routing here while
+	// in-flight and already-routed requests are still served
+	application.Services.Health.Drain()
+	if drain := time.Duration(cfg.ShutdownDrainSec) * time.Second; drain > 0 {
+		slog.Info("draining before shutdown", "seconds", cfg.ShutdownDrainSec)
+		time.Sleep(drain)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown", "error", err)
+	}
+	application.Close()
+	slog.Info("server gracefully stopped")
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/repository"
+	"github.com/example/blog-service/internal/service"
+)
+
+// userCmd manages the admins that may write when REQUIRE_ADMIN_ACTOR is on.
+// Admins are identified by the X-Actor-ID the gateway sends; the service
+// keeps no passwords or sessions.
+func userCmd(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "create-admin" {
+		return errUsage
+	}
+	fs := flag.NewFlagSet("user create-admin", flag.ExitOnError)
+	id := fs.String("id", "", "the admin's X-Actor-ID (required)")
+	name := fs.String("name", "", "display name")
+	_ = fs.Parse(args[1:])
+	if fs.NArg() > 0 || *id == "" {
+		return errUsage
+	}
+
+	database, err := db.Connect(cfg)
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+	if err := requireSchema(ctx, database); err != nil {
+		return err
+	}
+
+	admin, err := service.NewAdminService(repository.NewAdminRepository(database.Gorm)).Create(ctx, *id, *name)
+	if err != nil {
+		return fmt.Errorf("create-admin: %w", err)
+	}
+	fmt.Printf("created admin %s\n", admin.ID)
+	if !cfg.RequireAdminActor {
+		fmt.Println("note: REQUIRE_ADMIN_ACTOR is off, so anyone may still write")
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// Package activity carries who is making a request down to the code that
+// writes the audit trail.
+package activity
+
+import "context"
+
+// Actor identifies the origin of a write.
+type Actor struct {
+	ID        string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFrom returns the actor stored in ctx, or the zero Actor for writes
+// that don't come from a request (workers, CLI).
+func ActorFrom(ctx context.Context) Actor {
+	a, _ := ctx.Value(actorKey{}).(Actor)
+	return a
+}
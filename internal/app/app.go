@@ -3,46 +3,63 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/example/blog-service/internal/cache"
 	"github.com/example/blog-service/internal/config"
 	"github.com/example/blog-service/internal/db"
-	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/metrics"
 	"github.com/example/blog-service/internal/search"
+	"github.com/example/blog-service/internal/service"
+	"github.com/example/blog-service/internal/storage"
+	"github.com/example/blog-service/internal/tracing"
 	"github.com/example/blog-service/internal/transport/http"
 )
 
 type Application struct {
-	Config *config.Config
-	DB     *db.Database
-	Cache  *cache.RedisClient
-	Search *search.Elastic
-	Router http.Router
+	Config   *config.Config
+	DB       *db.Database
+	Cache    cache.Client
+	Search   *search.Elastic
+	Storage  storage.Storage
+	Services http.Services
+	Router   http.Router
+
+	MediaProcessor    *service.MediaProcessor
+	WebhookDispatcher *service.WebhookDispatcher
+	ActivityRetention *service.ActivityRetention
+
+	stopWorkers     context.CancelFunc
+	workers         sync.WaitGroup
+	shutdownTracing func(context.Context) error
 }
 
-func Initialize() (*Application, error) {
-	cfg := config.Load()
+// Initialize connects to every backend and wires the services; the
+// subcommands of cmd/app share it.
+func Initialize(cfg *config.Config) (*Application, error) {
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	database, err := db.Connect(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("db connect: %w", err)
 	}
 
-	if err := database.AutoMigrate(&models.Post{}, &models.ActivityLog{}); err != nil {
-		return nil, fmt.Errorf("db migrate: %w", err)
-	}
-	if err := database.EnsureGINIndexOnTags(); err != nil {
-		return nil, fmt.Errorf("ensure GIN index: %w", err)
+	if err := checkSchema(context.Background(), cfg, database); err != nil {
+		return nil, err
 	}
 
-	redisClient, err := cache.NewRedisClient(cfg)
+	cacheClient, err := cache.New(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("redis: %w", err)
+		return nil, fmt.Errorf("cache: %w", err)
 	}
 
-	es, err := search.NewElastic(cfg)
+	m := metrics.New()
+	es, err := search.NewElastic(cfg, m)
 	if err != nil {
 		return nil, fmt.Errorf("elasticsearch: %w", err)
 	}
@@ -52,24 +69,104 @@ func Initialize() (*Application, error) {
 		return nil, fmt.Errorf("ensure ES index: %w", err)
 	}
 
-	r := http.NewRouter(cfg, database, redisClient, es)
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	svc := http.NewServices(cfg, database, cacheClient, es, store, m)
+	router, err := http.NewRouter(cfg, svc)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Application{
-		Config: cfg,
-		DB:     database,
-		Cache:  redisClient,
-		Search: es,
-		Router: r,
+		Config:   cfg,
+		DB:       database,
+		Cache:    cacheClient,
+		Search:   es,
+		Storage:  store,
+		Services: svc,
+		Router:   router,
+
+		MediaProcessor:    service.NewMediaProcessor(cfg, database, store),
+		WebhookDispatcher: service.NewWebhookDispatcher(cfg, database),
+		ActivityRetention: service.NewActivityRetention(cfg, database),
+
+		shutdownTracing: shutdownTracing,
 	}, nil
 }
 
+// checkSchema deals with pending migrations as configured by DB_MIGRATIONS.
+func checkSchema(ctx context.Context, cfg *config.Config, database *db.Database) error {
+	if cfg.DBMigrations == "up" {
+		applied, err := database.MigrateUp(ctx)
+		if err != nil {
+			return fmt.Errorf("db migrate: %w", err)
+		}
+		for _, m := range applied {
+			slog.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+		return nil
+	}
+	pending, err := database.PendingMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("db migration status: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if cfg.DBMigrations == "require" {
+		return fmt.Errorf("database schema is behind: %d pending migrations starting at %d_%s; run app migrate up", len(pending), pending[0].Version, pending[0].Name)
+	}
+	slog.Warn("database schema is behind", "pending", len(pending), "next", fmt.Sprintf("%d_%s", pending[0].Version, pending[0].Name))
+	return nil
+}
+
+// StartWorkers runs the background workers until Close is called.
+func (a *Application) StartWorkers(ctx context.Context) {
+	ctx, a.stopWorkers = context.WithCancel(ctx)
+	a.workers.Add(5)
+	go func() {
+		defer a.workers.Done()
+		a.MediaProcessor.Run(ctx)
+	}()
+	go func() {
+		defer a.workers.Done()
+		a.Services.Media.RunSweeper(ctx)
+	}()
+	go func() {
+		defer a.workers.Done()
+		a.WebhookDispatcher.Run(ctx)
+	}()
+	go func() {
+		defer a.workers.Done()
+		a.ActivityRetention.Run(ctx)
+	}()
+	go func() {
+		defer a.workers.Done()
+		a.DB.WatchReplicas(ctx, time.Duration(a.Config.HealthCheckTimeoutMs)*time.Millisecond)
+	}()
+}
+
 func (a *Application) Close() {
+	if a.stopWorkers != nil {
+		a.stopWorkers()
+		a.workers.Wait()
+	}
 	if a.DB != nil {
 		if err := a.DB.Close(); err != nil {
-			log.Printf("db close error: %v", err)
+			slog.Error("db close", "error", err)
 		}
 	}
 	if a.Cache != nil {
 		_ = a.Cache.Close()
 	}
-} 
\ No newline at end of file
+	if a.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := a.shutdownTracing(ctx); err != nil {
+			slog.Error("tracing shutdown", "error", err)
+		}
+	}
+}
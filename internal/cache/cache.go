@@ -0,0 +1,69 @@
+// Package cache provides the JSON cache, counters and message broker the
+// services use, backed by Redis or, for local development and tests, by
+// process memory.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+// Cache stores JSON values with a TTL. A ttl of 0 means the backend's
+// default (CACHE_TTL_SECONDS).
+type Cache interface {
+	// Get unmarshals key into dest and reports whether it was present.
+	Get(ctx context.Context, key string, dest interface{}) (bool, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// MGet returns the raw JSON of those keys that are present.
+	MGet(ctx context.Context, keys ...string) (map[string][]byte, error)
+	Del(ctx context.Context, keys ...string) error
+	// Purge deletes every key starting with prefix and returns how many
+	// there were.
+	Purge(ctx context.Context, prefix string) (int, error)
+
+	// Fetch is a read-through Get: on a miss it calls load and caches the
+	// result, coalescing concurrent loads of the same key.
+	Fetch(ctx context.Context, key string, dest interface{}, load Loader) error
+
+	Generations(ctx context.Context, keys ...string) ([]int64, error)
+	BumpGenerations(ctx context.Context, keys ...string) error
+
+	Stats() Stats
+	// Ping checks that the backend is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Broker is an append-only stream plus publish/subscribe messaging.
+type Broker interface {
+	Append(ctx context.Context, stream string, maxLen int64, data []byte) (string, error)
+	ReadAfter(ctx context.Context, stream, afterID string, count int64) ([]StreamEntry, error)
+	Publish(ctx context.Context, channel string, data []byte) error
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+}
+
+// Client is a complete backend.
+type Client interface {
+	Cache
+	Broker
+}
+
+var (
+	_ Client = (*RedisClient)(nil)
+	_ Client = (*Memory)(nil)
+)
+
+// New returns the backend selected by CACHE_BACKEND.
+func New(cfg *config.Config) (Client, error) {
+	switch cfg.CacheBackend {
+	case "", "redis":
+		return NewRedisClient(cfg)
+	case "memory":
+		return NewMemory(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	mrand "math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// how long a caller that lost the lock polls for the winner's value
+	lockWait     = 2 * time.Second
+	lockPoll     = 50 * time.Millisecond
+	refreshLimit = 10 * time.Second
+)
+
+// unlockScript deletes the lock only if it still holds our token, so a
+// holder whose lock expired can't release somebody else's.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// ErrNotFound is returned by Fetch for a key whose loader reported the value
+// missing. Loaders return it (possibly wrapped) to have the miss cached for
+// the short negative TTL.
+var ErrNotFound = errors.New("cache: not found")
+
+// Stats are cumulative counters for Fetch.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	NegHits   uint64 `json:"negative_hits"`
+	Misses    uint64 `json:"misses"`
+	Stale     uint64 `json:"stale"`
+	Refreshes uint64 `json:"refreshes"`
+	LockWaits uint64 `json:"lock_waits"`
+	Errors    uint64 `json:"errors"`
+
+	// per namespace of the in-process cache
+	Local map[string]LocalStats `json:"local,omitempty"`
+}
+
+type counters struct {
+	hits, negHits, misses, stale, refreshes, lockWaits, errors atomic.Uint64
+}
+
+func (r *RedisClient) Stats() Stats {
+	return Stats{
+		Hits:      r.stats.hits.Load(),
+		NegHits:   r.stats.negHits.Load(),
+		Misses:    r.stats.misses.Load(),
+		Stale:     r.stats.stale.Load(),
+		Refreshes: r.stats.refreshes.Load(),
+		LockWaits: r.stats.lockWaits.Load(),
+		Errors:    r.stats.errors.Load(),
+		Local:     r.local.stats(),
+	}
+}
+
+// entry is what Fetch stores: the value plus the moment it goes stale. The
+// Redis TTL is later than that by the stale window. Missing marks a
+// negative entry, which has no value and is never served stale.
+type entry struct {
+	Value     json.RawMessage `json:"v,omitempty"`
+	FreshTill int64           `json:"f"`
+	Missing   bool            `json:"m,omitempty"`
+}
+
+// Loader produces the value for a missing or stale key.
+type Loader func(ctx context.Context) (interface{}, error)
+
+// Fetch reads key into dest, calling load on a miss. It protects the
+// source behind load from stampedes:
+//   - concurrent misses in this process share one load (singleflight);
+//   - across processes a short Redis lock lets one load while the others
+//     wait briefly for its result;
+//   - past the soft TTL the stale value is returned right away and a single
+//     background refresh replaces it.
+//
+// Errors from load are returned as-is and not cached, except ErrNotFound,
+// which is cached for the negative TTL.
+func (r *RedisClient) Fetch(ctx context.Context, key string, dest interface{}, load Loader) error {
+	if b, ok := r.local.get(key); ok {
+		return json.Unmarshal(b, dest)
+	}
+	seq := r.local.version()
+	raw, err := r.client.Get(ctx, key).Bytes()
+	if err != nil && err != redis.Nil {
+		r.stats.errors.Add(1)
+	}
+	if err == nil {
+		var e entry
+		if json.Unmarshal(raw, &e) == nil {
+			if e.Missing {
+				r.stats.negHits.Add(1)
+				return ErrNotFound
+			}
+			if time.Now().UnixMilli() < e.FreshTill {
+				r.stats.hits.Add(1)
+				r.local.put(key, e.Value, time.UnixMilli(e.FreshTill), seq)
+			} else {
+				r.stats.stale.Add(1)
+				r.refreshAsync(ctx, key, load)
+			}
+			return json.Unmarshal(e.Value, dest)
+		}
+	}
+
+	r.stats.misses.Add(1)
+	v, err, _ := r.group.Do(key, func() (interface{}, error) {
+		return r.loadLocked(ctx, key, load)
+	})
+	if err != nil {
+		return err
+	}
+	r.local.put(key, v.(json.RawMessage), time.Time{}, seq)
+	return json.Unmarshal(v.(json.RawMessage), dest)
+}
+
+// loadLocked loads and stores key under the cross-process lock. If another
+// process holds it, wait for its value, and fall back to loading anyway so a
+// slow or crashed holder can't fail requests.
+func (r *RedisClient) loadLocked(ctx context.Context, key string, load Loader) (json.RawMessage, error) {
+	token, locked := r.lock(ctx, key)
+	if locked {
+		defer r.unlock(ctx, key, token)
+	} else {
+		r.stats.lockWaits.Add(1)
+		if e, ok := r.waitFor(ctx, key); ok {
+			if e.Missing {
+				return nil, ErrNotFound
+			}
+			return e.Value, nil
+		}
+	}
+	return r.loadAndStore(ctx, key, load)
+}
+
+func (r *RedisClient) loadAndStore(ctx context.Context, key string, load Loader) (json.RawMessage, error) {
+	v, err := load(ctx)
+	if errors.Is(err, ErrNotFound) {
+		if r.negTTL > 0 {
+			e, _ := json.Marshal(entry{Missing: true})
+			if err := r.client.Set(ctx, key, e, r.negTTL).Err(); err != nil {
+				r.stats.errors.Add(1)
+			}
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	soft := r.jittered(r.ttl)
+	e, _ := json.Marshal(entry{Value: b, FreshTill: time.Now().Add(soft).UnixMilli()})
+	if err := r.client.Set(ctx, key, e, soft+r.staleFor).Err(); err != nil {
+		r.stats.errors.Add(1)
+	}
+	return b, nil
+}
+
+// refreshAsync reloads a stale key in the background unless this process or
+// another one is already doing so.
+func (r *RedisClient) refreshAsync(ctx context.Context, key string, load Loader) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_, _, _ = r.group.Do("refresh:"+key, func() (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, refreshLimit)
+			defer cancel()
+			token, ok := r.lock(ctx, key)
+			if !ok {
+				return nil, nil
+			}
+			defer r.unlock(ctx, key, token)
+			r.stats.refreshes.Add(1)
+			if _, err := r.loadAndStore(ctx, key, load); err != nil {
+				slog.WarnContext(ctx, "cache refresh failed", "key", key, "error", err)
+			}
+			return nil, nil
+		})
+	}()
+}
+
+func (r *RedisClient) waitFor(ctx context.Context, key string) (*entry, bool) {
+	deadline := time.Now().Add(lockWait)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(lockPoll):
+		}
+		raw, err := r.client.Get(ctx, key).Bytes()
+		if err != nil {
+			continue
+		}
+		var e entry
+		if json.Unmarshal(raw, &e) == nil {
+			return &e, true
+		}
+	}
+	return nil, false
+}
+
+func (r *RedisClient) lock(ctx context.Context, key string) (string, bool) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+	ok, err := r.client.SetNX(ctx, "lock:"+key, token, r.lockTTL).Result()
+	if err != nil {
+		r.stats.errors.Add(1)
+		// without Redis there is nobody to coordinate with
+		return "", !errors.Is(err, context.Canceled)
+	}
+	return token, ok
+}
+
+func (r *RedisClient) unlock(ctx context.Context, key, token string) {
+	if token == "" {
+		return
+	}
+	_ = unlockScript.Run(ctx, r.client, []string{"lock:" + key}, token).Err()
+}
+
+// jittered spreads d by up to ±jitter so keys written together don't expire together.
+func (r *RedisClient) jittered(d time.Duration) time.Duration {
+	if r.jitter <= 0 || d <= 0 {
+		return d
+	}
+	spread := float64(d) * r.jitter
+	return d + time.Duration((mrand.Float64()*2-1)*spread)
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+// newTestRedis returns a RedisClient on a fresh miniredis.
+func newTestRedis(t *testing.T, local ...config.LocalCacheNamespace) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	r, err := NewRedisClient(&config.Config{
+		RedisAddr:        mr.Addr(),
+		CacheTTLSec:      60,
+		CacheStaleSec:    60,
+		CacheNegativeSec: 30,
+		CacheLockMs:      5000,
+		CacheLocal:       local,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, mr
+}
+
+func setEntry(t *testing.T, mr *miniredis.Miniredis, key string, e entry) {
+	t.Helper()
+	b, _ := json.Marshal(e)
+	if err := mr.Set(key, string(b)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually fails the test unless cond holds within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestFetchServesStaleWhileRevalidating(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx := context.Background()
+	setEntry(t, mr, "k", entry{Value: json.RawMessage(`"old"`), FreshTill: time.Now().Add(-time.Second).UnixMilli()})
+
+	var loads atomic.Int32
+	load := func(context.Context) (interface{}, error) {
+		loads.Add(1)
+		return "new", nil
+	}
+	var got string
+	if err := r.Fetch(ctx, "k", &got, load); err != nil || got != "old" {
+		t.Fatalf("Fetch = %q, %v; want the stale value right away", got, err)
+	}
+	eventually(t, "the refresh", func() bool {
+		got = ""
+		return r.Fetch(ctx, "k", &got, load) == nil && got == "new"
+	})
+	// every stale read before the refresh landed shares that one refresh
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+	if s := r.Stats(); s.Stale == 0 || s.Refreshes != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestFetchCachesMisses(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx := context.Background()
+	var loads int
+	load := func(context.Context) (interface{}, error) {
+		loads++
+		return nil, ErrNotFound
+	}
+	for i := 0; i < 2; i++ {
+		var got string
+		if err := r.Fetch(ctx, "gone", &got, load); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch err = %v, want ErrNotFound", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want the miss cached", loads)
+	}
+	if ttl := mr.TTL("gone"); ttl != 30*time.Second {
+		t.Errorf("TTL = %s, want the negative TTL", ttl)
+	}
+
+	// other errors are not cached
+	fail := errors.New("db down")
+	for i := 0; i < 2; i++ {
+		var got string
+		err := r.Fetch(ctx, "flaky", &got, func(context.Context) (interface{}, error) {
+			loads++
+			return nil, fail
+		})
+		if !errors.Is(err, fail) {
+			t.Fatalf("Fetch err = %v", err)
+		}
+	}
+	if loads != 3 {
+		t.Errorf("loaded %d times, want errors retried", loads)
+	}
+}
+
+// When another process holds the lock, Fetch waits for the value it stores
+// instead of loading too.
+func TestFetchWaitsForLockHolder(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx := context.Background()
+	mr.Set("lock:k", "other")
+
+	done := make(chan error)
+	var got string
+	go func() {
+		done <- r.Fetch(ctx, "k", &got, func(context.Context) (interface{}, error) {
+			return "ours", nil
+		})
+	}()
+	time.Sleep(2 * lockPoll)
+	setEntry(t, mr, "k", entry{Value: json.RawMessage(`"theirs"`), FreshTill: time.Now().Add(time.Minute).UnixMilli()})
+
+	select {
+	case err := <-done:
+		if err != nil || got != "theirs" {
+			t.Errorf("Fetch = %q, %v; want the holder's value", got, err)
+		}
+	case <-time.After(lockWait + time.Second):
+		t.Fatal("Fetch did not return")
+	}
+	if s := r.Stats(); s.LockWaits != 1 {
+		t.Errorf("lock waits = %d, want 1", s.LockWaits)
+	}
+}
+
+// go-redis resubscribes by itself after a dropped connection; whatever was
+// invalidated meanwhile was missed, so the local tier must start over.
+func TestLocalTierFlushedOnResubscribe(t *testing.T) {
+	r, mr := newTestRedis(t, config.LocalCacheNamespace{Prefix: "post:", MaxEntries: 10, TTL: time.Minute})
+	ctx := context.Background()
+	subscribed := func() bool { return mr.PubSubNumSub(invalidationChannel)[invalidationChannel] == 1 }
+	eventually(t, "the first subscription", subscribed)
+
+	if err := r.Set(ctx, "post:1", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.local.get("post:1"); !ok {
+		t.Fatal("not cached locally")
+	}
+
+	mr.Close()
+	if err := mr.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the resubscription", subscribed)
+	eventually(t, "the flush", func() bool {
+		_, ok := r.local.get("post:1")
+		return !ok
+	})
+}
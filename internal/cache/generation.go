@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Generation counters version groups of cached results. Result keys embed
+// the current generation, so bumping it orphans every older entry at once
+// (they expire on their own) without enumerating keys.
+
+// Generations reads the counters for keys; missing counters read as 0.
+func (r *RedisClient) Generations(ctx context.Context, keys ...string) ([]int64, error) {
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, len(keys))
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[i], _ = redis.NewStringResult(s, nil).Int64()
+		}
+	}
+	return out, nil
+}
+
+// BumpGenerations increments the counters for keys in one round trip.
+func (r *RedisClient) BumpGenerations(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, k := range keys {
+			p.Incr(ctx, k)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+// invalidationChannel carries keys deleted on any replica so every process
+// can drop its local copy.
+const invalidationChannel = "cache:invalidate"
+
+// localTier is the in-process cache in front of Redis. Each configured key
+// namespace (a key prefix) gets its own LRU; keys outside every namespace
+// are not cached locally.
+type localTier struct {
+	namespaces []localNamespace
+	// bumped by every invalidation; a value read from Redis before the bump
+	// may predate the write behind it and must not be kept
+	seq atomic.Uint64
+}
+
+type localNamespace struct {
+	prefix string
+	cache  *lru
+}
+
+// LocalStats describes one namespace of the in-process cache.
+type LocalStats struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	Size      int     `json:"size"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+func newLocalTier(specs []config.LocalCacheNamespace) *localTier {
+	if len(specs) == 0 {
+		return nil
+	}
+	t := &localTier{}
+	for _, s := range specs {
+		if s.MaxEntries > 0 && s.TTL > 0 {
+			t.namespaces = append(t.namespaces, localNamespace{prefix: s.Prefix, cache: newLRU(s.MaxEntries, s.TTL)})
+		}
+	}
+	return t
+}
+
+// lookup returns the LRU for key's longest matching namespace.
+func (t *localTier) lookup(key string) *lru {
+	if t == nil {
+		return nil
+	}
+	var best *localNamespace
+	for i := range t.namespaces {
+		ns := &t.namespaces[i]
+		if strings.HasPrefix(key, ns.prefix) && (best == nil || len(ns.prefix) > len(best.prefix)) {
+			best = ns
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.cache
+}
+
+func (t *localTier) get(key string) ([]byte, bool) {
+	if c := t.lookup(key); c != nil {
+		return c.get(key)
+	}
+	return nil, false
+}
+
+// put keeps value unless an invalidation happened since seq was read.
+func (t *localTier) put(key string, value []byte, notAfter time.Time, seq uint64) {
+	c := t.lookup(key)
+	if c == nil || t.seq.Load() != seq {
+		return
+	}
+	c.put(key, value, notAfter)
+}
+
+func (t *localTier) version() uint64 {
+	if t == nil {
+		return 0
+	}
+	return t.seq.Load()
+}
+
+func (t *localTier) evict(keys ...string) {
+	if t == nil {
+		return
+	}
+	t.seq.Add(1)
+	for _, k := range keys {
+		if c := t.lookup(k); c != nil {
+			c.remove(k)
+		}
+	}
+}
+
+func (t *localTier) stats() map[string]LocalStats {
+	if t == nil {
+		return nil
+	}
+	out := make(map[string]LocalStats, len(t.namespaces))
+	for _, ns := range t.namespaces {
+		s := LocalStats{
+			Hits:      ns.cache.hits.Load(),
+			Misses:    ns.cache.misses.Load(),
+			Evictions: ns.cache.evictions.Load(),
+			Size:      ns.cache.len(),
+		}
+		if total := s.Hits + s.Misses; total > 0 {
+			s.HitRate = float64(s.Hits) / float64(total)
+		}
+		out[ns.prefix] = s
+	}
+	return out
+}
+
+// listenInvalidations evicts keys deleted on other replicas until ctx is
+// cancelled. Messages are JSON arrays of keys. go-redis resubscribes on its
+// own after the connection drops, so every subscribe confirmation, the first
+// one included, flushes the tier: anything may have changed while we
+// weren't listening.
+func (r *RedisClient) listenInvalidations(ctx context.Context) {
+	ps := r.client.Subscribe(ctx, invalidationChannel)
+	defer ps.Close()
+	msgs := ps.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-msgs:
+			if !ok {
+				return
+			}
+			switch m := m.(type) {
+			case *redis.Subscription:
+				if m.Kind == "subscribe" {
+					r.local.evictAll()
+				}
+			case *redis.Message:
+				var keys []string
+				if json.Unmarshal([]byte(m.Payload), &keys) == nil {
+					r.local.evict(keys...)
+				}
+			}
+		}
+	}
+}
+
+func (t *localTier) evictAll() {
+	t.seq.Add(1)
+	for _, ns := range t.namespaces {
+		ns.cache.clear()
+	}
+}
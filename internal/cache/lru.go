@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lru is a size- and TTL-bounded in-process cache of raw JSON values.
+// Values are stored as bytes so callers never share mutable state.
+type lru struct {
+	mu      sync.Mutex
+	max     int
+	ttl     time.Duration
+	ll      *list.List
+	entries map[string]*list.Element
+
+	hits, misses, evictions atomic.Uint64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func newLRU(max int, ttl time.Duration) *lru {
+	return &lru{max: max, ttl: ttl, ll: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lru) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.removeElement(el)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// put stores value until the earlier of the LRU's TTL and notAfter, either
+// of which may be unset.
+func (c *lru) put(key string, value []byte, notAfter time.Time) {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	if !notAfter.IsZero() && (expires.IsZero() || notAfter.Before(expires)) {
+		expires = notAfter
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = value, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.max {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *lru) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// removePrefix drops every entry whose key starts with prefix and returns
+// how many there were.
+func (c *lru) removePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for key, el := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *lru) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
+
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.entries = make(map[string]*list.Element)
+}
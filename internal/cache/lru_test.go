@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+func TestLRUExpiry(t *testing.T) {
+	c := newLRU(10, 30*time.Millisecond)
+	c.put("ttl", []byte("1"), time.Time{})
+	c.put("not-after", []byte("2"), time.Now().Add(10*time.Millisecond))
+	for _, k := range []string{"ttl", "not-after"} {
+		if _, ok := c.get(k); !ok {
+			t.Fatalf("%s missing right after put", k)
+		}
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if _, ok := c.get("not-after"); ok {
+		t.Error("kept past notAfter, which is earlier than the TTL")
+	}
+	if _, ok := c.get("ttl"); !ok {
+		t.Error("expired before its TTL")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.get("ttl"); ok {
+		t.Error("kept past its TTL")
+	}
+	if c.len() != 0 {
+		t.Errorf("len = %d, want expired entries removed", c.len())
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRU(3, 0)
+	for _, k := range []string{"a", "b", "c"} {
+		c.put(k, []byte(k), time.Time{})
+	}
+	c.get("a")                            // b is now the least recently used
+	c.put("c", []byte("c2"), time.Time{}) // an update counts as a use
+	c.put("d", []byte("d"), time.Time{})
+	c.put("e", []byte("e"), time.Time{})
+
+	if c.len() != 3 {
+		t.Fatalf("len = %d, want 3", c.len())
+	}
+	for k, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true, "e": true} {
+		if _, ok := c.get(k); ok != want {
+			t.Errorf("%s present = %v, want %v", k, ok, want)
+		}
+	}
+	if n := c.evictions.Load(); n != 2 {
+		t.Errorf("evictions = %d, want 2", n)
+	}
+	if v, _ := c.get("c"); string(v) != "c2" {
+		t.Errorf("c = %s, want the updated value", v)
+	}
+}
+
+// A value read from Redis before an invalidation must not be cached after
+// it: it may predate the write that caused the invalidation.
+func TestLocalTierDropsFillsOlderThanInvalidation(t *testing.T) {
+	tier := newLocalTier([]config.LocalCacheNamespace{{Prefix: "post:", MaxEntries: 10, TTL: time.Minute}})
+
+	seq := tier.version()
+	tier.evict("post:1")
+	tier.put("post:1", []byte("old"), time.Time{}, seq)
+	if _, ok := tier.get("post:1"); ok {
+		t.Error("kept a fill that started before an invalidation")
+	}
+
+	seq = tier.version()
+	tier.put("post:1", []byte("new"), time.Time{}, seq)
+	if v, ok := tier.get("post:1"); !ok || string(v) != "new" {
+		t.Errorf("get = %s, %v; want the fill that started after it", v, ok)
+	}
+
+	seq = tier.version()
+	tier.evictAll()
+	tier.put("post:2", []byte("old"), time.Time{}, seq)
+	if _, ok := tier.get("post:2"); ok {
+		t.Error("kept a fill that started before a flush")
+	}
+	if _, ok := tier.get("post:1"); ok {
+		t.Error("flush left entries behind")
+	}
+}
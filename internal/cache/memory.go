@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+const memorySubscriberBuf = 256
+
+// Memory is a single-process Client for local development and tests. It is
+// bounded by CACHE_MEMORY_MAX_ENTRIES (least recently used keys go first)
+// and honours TTLs, but has no cross-process coordination: Fetch coalesces
+// loads within the process and entries are never served stale.
+type Memory struct {
+	items  *lru
+	ttl    time.Duration
+	negTTL time.Duration
+	group  singleflight.Group
+	stats  counters
+
+	mu      sync.Mutex
+	gens    map[string]int64
+	streams map[string]*memoryStream
+	subs    map[string]map[chan []byte]struct{}
+}
+
+type memoryStream struct {
+	entries []StreamEntry
+	lastMs  int64
+	lastSeq int64
+}
+
+func NewMemory(cfg *config.Config) *Memory {
+	max := cfg.CacheMemoryMaxEntries
+	if max <= 0 {
+		max = 100000
+	}
+	return &Memory{
+		items:   newLRU(max, 0),
+		ttl:     time.Duration(cfg.CacheTTLSec) * time.Second,
+		negTTL:  time.Duration(cfg.CacheNegativeSec) * time.Second,
+		gens:    make(map[string]int64),
+		streams: make(map[string]*memoryStream),
+		subs:    make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+func (m *Memory) Close() error { return nil }
+
+func (m *Memory) Ping(ctx context.Context) error { return nil }
+
+func (m *Memory) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	b, ok := m.items.get(key)
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, dest)
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.put(key, b, ttl)
+	return nil
+}
+
+func (m *Memory) put(key string, b []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = m.ttl
+	}
+	m.items.put(key, b, time.Now().Add(ttl))
+}
+
+func (m *Memory) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		if b, ok := m.items.get(k); ok {
+			out[k] = b
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) Del(ctx context.Context, keys ...string) error {
+	for _, k := range keys {
+		m.items.remove(k)
+	}
+	return nil
+}
+
+func (m *Memory) Fetch(ctx context.Context, key string, dest interface{}, load Loader) error {
+	if b, ok := m.items.get(key); ok {
+		var e entry
+		if json.Unmarshal(b, &e) == nil {
+			if e.Missing {
+				m.stats.negHits.Add(1)
+				return ErrNotFound
+			}
+			m.stats.hits.Add(1)
+			return json.Unmarshal(e.Value, dest)
+		}
+	}
+	m.stats.misses.Add(1)
+	v, err, _ := m.group.Do(key, func() (interface{}, error) {
+		v, err := load(ctx)
+		if errors.Is(err, ErrNotFound) {
+			if m.negTTL > 0 {
+				e, _ := json.Marshal(entry{Missing: true})
+				m.put(key, e, m.negTTL)
+			}
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		e, _ := json.Marshal(entry{Value: b, FreshTill: time.Now().Add(m.ttl).UnixMilli()})
+		m.put(key, e, m.ttl)
+		return json.RawMessage(b), nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(v.(json.RawMessage), dest)
+}
+
+func (m *Memory) Generations(ctx context.Context, keys ...string) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		out[i] = m.gens[k]
+	}
+	return out, nil
+}
+
+func (m *Memory) BumpGenerations(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		m.gens[k]++
+	}
+	return nil
+}
+
+func (m *Memory) Stats() Stats {
+	return Stats{
+		Hits:    m.stats.hits.Load(),
+		NegHits: m.stats.negHits.Load(),
+		Misses:  m.stats.misses.Load(),
+	}
+}
+
+// Append mimics XADD: IDs are "<unix ms>-<seq>" and strictly increasing.
+func (m *Memory) Append(ctx context.Context, stream string, maxLen int64, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.streams[stream]
+	if s == nil {
+		s = &memoryStream{}
+		m.streams[stream] = s
+	}
+	ms := time.Now().UnixMilli()
+	if ms > s.lastMs {
+		s.lastMs, s.lastSeq = ms, 0
+	} else {
+		s.lastSeq++
+	}
+	id := fmt.Sprintf("%d-%d", s.lastMs, s.lastSeq)
+	s.entries = append(s.entries, StreamEntry{ID: id, Data: append([]byte(nil), data...)})
+	if maxLen > 0 && int64(len(s.entries)) > maxLen {
+		s.entries = append([]StreamEntry(nil), s.entries[int64(len(s.entries))-maxLen:]...)
+	}
+	return id, nil
+}
+
+func (m *Memory) ReadAfter(ctx context.Context, stream, afterID string, count int64) ([]StreamEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.streams[stream]
+	if s == nil {
+		return nil, nil
+	}
+	var out []StreamEntry
+	for _, e := range s.entries {
+		if streamIDAfter(e.ID, afterID) {
+			out = append(out, e)
+			if int64(len(out)) == count {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// Publish drops the message for subscribers whose buffer is full rather
+// than blocking the publisher.
+func (m *Memory) Publish(ctx context.Context, channel string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.subs[channel] {
+		select {
+		case ch <- append([]byte(nil), data...):
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	ch := make(chan []byte, memorySubscriberBuf)
+	m.mu.Lock()
+	if m.subs[channel] == nil {
+		m.subs[channel] = make(map[chan []byte]struct{})
+	}
+	m.subs[channel][ch] = struct{}{}
+	m.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subs[channel], ch)
+		close(ch)
+		m.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+func streamIDAfter(a, b string) bool {
+	ams, aseq := splitStreamID(a)
+	bms, bseq := splitStreamID(b)
+	return ams > bms || (ams == bms && aseq > bseq)
+}
+
+func splitStreamID(id string) (int64, int64) {
+	for i := 0; i < len(id); i++ {
+		if id[i] == '-' {
+			ms, _ := strconv.ParseInt(id[:i], 10, 64)
+			seq, _ := strconv.ParseInt(id[i+1:], 10, 64)
+			return ms, seq
+		}
+	}
+	ms, _ := strconv.ParseInt(id, 10, 64)
+	return ms, 0
+}
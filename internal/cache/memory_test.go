@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+func newTestMemory() *Memory {
+	return NewMemory(&config.Config{CacheMemoryMaxEntries: 2, CacheTTLSec: 60, CacheNegativeSec: 60})
+}
+
+func TestMemoryGetSet(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemory()
+	if err := m.Set(ctx, "a", map[string]int{"n": 1}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(ctx, "short", 2, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]int
+	if ok, err := m.Get(ctx, "a", &got); !ok || err != nil || got["n"] != 1 {
+		t.Fatalf("Get = %v, %v, %v", got, ok, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	var n int
+	if ok, _ := m.Get(ctx, "short", &n); ok {
+		t.Error("kept past its TTL")
+	}
+
+	// bounded by CACHE_MEMORY_MAX_ENTRIES, least recently used first
+	m.Set(ctx, "b", 2, 0)
+	m.Get(ctx, "a", &got)
+	m.Set(ctx, "c", 3, 0)
+	vals, _ := m.MGet(ctx, "a", "b", "c")
+	if _, ok := vals["b"]; ok || len(vals) != 2 {
+		t.Errorf("MGet = %v, want a and c", vals)
+	}
+
+	m.Del(ctx, "a")
+	if ok, _ := m.Get(ctx, "a", &got); ok {
+		t.Error("present after Del")
+	}
+}
+
+func TestMemoryPurge(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(&config.Config{CacheTTLSec: 60})
+	m.Set(ctx, "feed:1", 1, 0)
+	m.Set(ctx, "feed:2", 2, 0)
+	m.Set(ctx, "post:1", 3, 0)
+	m.BumpGenerations(ctx, "feed:gen", "post:gen")
+
+	n, err := m.Purge(ctx, "feed:")
+	if err != nil || n != 3 {
+		t.Fatalf("Purge = %d, %v; want 3", n, err)
+	}
+	gens, _ := m.Generations(ctx, "feed:gen", "post:gen")
+	if gens[0] != 0 || gens[1] != 1 {
+		t.Errorf("generations = %v, want [0 1]", gens)
+	}
+	var v int
+	if ok, _ := m.Get(ctx, "post:1", &v); !ok {
+		t.Error("purged a key outside the prefix")
+	}
+}
+
+func TestMemoryFetch(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemory()
+	var loads int
+	load := func(context.Context) (interface{}, error) {
+		loads++
+		return "value", nil
+	}
+	for i := 0; i < 2; i++ {
+		var got string
+		if err := m.Fetch(ctx, "k", &got, load); err != nil || got != "value" {
+			t.Fatalf("Fetch = %q, %v", got, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+
+	missing := func(context.Context) (interface{}, error) {
+		loads++
+		return nil, ErrNotFound
+	}
+	for i := 0; i < 2; i++ {
+		var got string
+		if err := m.Fetch(ctx, "gone", &got, missing); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch err = %v, want ErrNotFound", err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("loaded %d times, want the miss cached", loads)
+	}
+	if s := m.Stats(); s.Hits != 1 || s.NegHits != 1 || s.Misses != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestMemoryStream(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemory()
+	var ids []string
+	for _, d := range []string{"a", "b", "c"} {
+		id, err := m.Append(ctx, "s", 2, []byte(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) > 0 && !streamIDAfter(id, ids[len(ids)-1]) {
+			t.Fatalf("ID %s does not follow %s", id, ids[len(ids)-1])
+		}
+		ids = append(ids, id)
+	}
+	got, _ := m.ReadAfter(ctx, "s", "0-0", 10)
+	if len(got) != 2 || string(got[0].Data) != "b" || string(got[1].Data) != "c" {
+		t.Errorf("ReadAfter = %v, want b and c after trimming to 2", got)
+	}
+	got, _ = m.ReadAfter(ctx, "s", ids[1], 10)
+	if len(got) != 1 || got[0].ID != ids[2] {
+		t.Errorf("ReadAfter(%s) = %v, want only c", ids[1], got)
+	}
+}
+
+func TestMemoryPubSub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := newTestMemory()
+	msgs, err := m.Subscribe(ctx, "ch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Publish(context.Background(), "other", []byte("no"))
+	m.Publish(context.Background(), "ch", []byte("yes"))
+	if got := <-msgs; string(got) != "yes" {
+		t.Errorf("got %s", got)
+	}
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Error("unexpected message")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after ctx ended")
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"strings"
+)
+
+const purgeBatch = 500
+
+// Purge deletes every key starting with prefix and returns how many it
+// removed. Keys go in batches through Del, so local tiers in other
+// processes drop them too.
+func (r *RedisClient) Purge(ctx context.Context, prefix string) (int, error) {
+	var n int
+	iter := r.client.Scan(ctx, 0, globEscape(prefix)+"*", purgeBatch).Iterator()
+	batch := make([]string, 0, purgeBatch)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == purgeBatch {
+			if err := r.Del(ctx, batch...); err != nil {
+				return n, err
+			}
+			n += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, err
+	}
+	if len(batch) > 0 {
+		if err := r.Del(ctx, batch...); err != nil {
+			return n, err
+		}
+		n += len(batch)
+	}
+	return n, nil
+}
+
+// globEscape quotes the characters SCAN MATCH treats as wildcards.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (m *Memory) Purge(ctx context.Context, prefix string) (int, error) {
+	n := m.items.removePrefix(prefix)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.gens {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.gens, k)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/example/blog-service/internal/config"
 )
@@ -13,6 +15,17 @@ import (
 type RedisClient struct {
 	client *redis.Client
 	ttl    time.Duration
+
+	// stampede protection, see Fetch
+	group    singleflight.Group
+	staleFor time.Duration
+	negTTL   time.Duration
+	lockTTL  time.Duration
+	jitter   float64
+	stats    counters
+
+	local           *localTier
+	stopInvalidator context.CancelFunc
 }
 
 func NewRedisClient(cfg *config.Config) (*RedisClient, error) {
@@ -21,12 +34,42 @@ func NewRedisClient(cfg *config.Config) (*RedisClient, error) {
 		Password: cfg.RedisPassword,
 		DB:       cfg.RedisDB,
 	})
-	return &RedisClient{client: c, ttl: time.Duration(cfg.CacheTTLSec) * time.Second}, nil
+	if err := redisotel.InstrumentTracing(c); err != nil {
+		return nil, err
+	}
+	r := &RedisClient{
+		client:   c,
+		ttl:      time.Duration(cfg.CacheTTLSec) * time.Second,
+		staleFor: time.Duration(cfg.CacheStaleSec) * time.Second,
+		negTTL:   time.Duration(cfg.CacheNegativeSec) * time.Second,
+		lockTTL:  time.Duration(cfg.CacheLockMs) * time.Millisecond,
+		jitter:   float64(cfg.CacheJitterPct) / 100,
+		local:    newLocalTier(cfg.CacheLocal),
+	}
+	if r.local != nil {
+		var ctx context.Context
+		ctx, r.stopInvalidator = context.WithCancel(context.Background())
+		go r.listenInvalidations(ctx)
+	}
+	return r, nil
 }
 
-func (r *RedisClient) Close() error { return r.client.Close() }
+func (r *RedisClient) Close() error {
+	if r.stopInvalidator != nil {
+		r.stopInvalidator()
+	}
+	return r.client.Close()
+}
+
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
 
-func (r *RedisClient) GetJSON(ctx context.Context, key string, dest interface{}) (bool, error) {
+func (r *RedisClient) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	if b, ok := r.local.get(key); ok {
+		return true, json.Unmarshal(b, dest)
+	}
+	seq := r.local.version()
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return false, nil
@@ -34,17 +77,60 @@ func (r *RedisClient) GetJSON(ctx context.Context, key string, dest interface{})
 	if err != nil {
 		return false, err
 	}
+	r.local.put(key, []byte(val), time.Time{}, seq)
 	return true, json.Unmarshal([]byte(val), dest)
 }
 
-func (r *RedisClient) SetJSON(ctx context.Context, key string, value interface{}) error {
+func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	b, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, key, b, r.ttl).Err()
+	if ttl <= 0 {
+		ttl = r.ttl
+	}
+	seq := r.local.version()
+	if err := r.client.Set(ctx, key, b, r.jittered(ttl)).Err(); err != nil {
+		return err
+	}
+	r.local.put(key, b, time.Time{}, seq)
+	return nil
+}
+
+func (r *RedisClient) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(keys))
+	var missing []string
+	for _, k := range keys {
+		if b, ok := r.local.get(k); ok {
+			out[k] = b
+		} else {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+	vals, err := r.client.MGet(ctx, missing...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[missing[i]] = []byte(s)
+		}
+	}
+	return out, nil
 }
 
-func (r *RedisClient) Del(ctx context.Context, key string) error {
-	return r.client.Del(ctx, key).Err()
+// Del removes keys from Redis and from the local cache of every replica.
+func (r *RedisClient) Del(ctx context.Context, keys ...string) error {
+	err := r.client.Del(ctx, keys...).Err()
+	r.local.evict(keys...)
+	if r.local != nil {
+		msg, _ := json.Marshal(keys)
+		if pubErr := r.client.Publish(ctx, invalidationChannel, msg).Err(); pubErr != nil && err == nil {
+			err = pubErr
+		}
+	}
+	return err
 } 
\ No newline at end of file
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StreamEntry is one entry of a Redis stream written by Append.
+type StreamEntry struct {
+	ID   string
+	Data []byte
+}
+
+// Append adds data to stream, trimming it to roughly maxLen entries, and
+// returns the entry ID.
+func (r *RedisClient) Append(ctx context.Context, stream string, maxLen int64, data []byte) (string, error) {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: map[string]interface{}{"data": data},
+	}).Result()
+}
+
+// ReadAfter returns up to count entries of stream that come after afterID.
+func (r *RedisClient) ReadAfter(ctx context.Context, stream, afterID string, count int64) ([]StreamEntry, error) {
+	msgs, err := r.client.XRangeN(ctx, stream, "("+afterID, "+", count).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StreamEntry, 0, len(msgs))
+	for _, m := range msgs {
+		data, _ := m.Values["data"].(string)
+		out = append(out, StreamEntry{ID: m.ID, Data: []byte(data)})
+	}
+	return out, nil
+}
+
+func (r *RedisClient) Publish(ctx context.Context, channel string, data []byte) error {
+	return r.client.Publish(ctx, channel, data).Err()
+}
+
+// Subscribe delivers messages published on channel until ctx is cancelled,
+// then closes the returned channel.
+func (r *RedisClient) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	ps := r.client.Subscribe(ctx, channel)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		defer ps.Close()
+		msgs := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(m.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
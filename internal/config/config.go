@@ -3,10 +3,38 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Port string
+	Port          string
+	PublicBaseURL string
+	SiteTitle     string
+	FeedSize      int
+	// timeout of each /readyz dependency check, and how long the server
+	// keeps serving with /readyz failing before it shuts down
+	HealthCheckTimeoutMs int
+	ShutdownDrainSec     int
+	// proxies (IPs or CIDRs) whose X-Forwarded-For is believed for client
+	// IPs, as logged and recorded in the activity log; none by default
+	TrustedProxies []string
+	// only registered admins (app user create-admin) may write; the
+	// X-Actor-ID the gateway sets says who is calling
+	RequireAdminActor bool
+
+	// slog level (debug, info, warn, error) and format (json, text)
+	LogLevel  string
+	LogFormat string
+
+	// where spans go: "none", "otlp" (OTLP/HTTP to TracingEndpoint) or
+	// "stdout"; the fraction of new traces sampled (requests that arrive
+	// with a sampled parent are always traced)
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingSampleRatio float64
+	TracingServiceName string
 
 	DBHost     string
 	DBPort     string
@@ -15,52 +43,248 @@ type Config struct {
 	DBName     string
 	DBSSLMode  string
 	DBTimezone string
+	// what the server does about pending migrations at startup: "warn" logs
+	// them, "require" refuses to start, "up" applies them
+	DBMigrations string
+	// queries slower than this are logged as warnings
+	DBSlowQueryMs int
+	// pool limits of the primary and of each replica; lifetimes of 0 keep
+	// connections open indefinitely
+	DBMaxOpenConns       int
+	DBMaxIdleConns       int
+	DBConnMaxLifetimeSec int
+	DBConnMaxIdleSec     int
+	// session timeouts; 0 leaves the server's defaults
+	DBStatementTimeoutMs int
+	DBConnectTimeoutSec  int
+	// postgres:// URLs of read replicas for reads that tolerate lag
+	DBReplicaDSNs []string
+	// how long after a write cache fills of what it touched read from the
+	// primary; meant to exceed the replicas' usual lag
+	DBReplicaLagSec int
+
+	CacheBackend          string
+	CacheMemoryMaxEntries int
 
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
 	CacheTTLSec   int
+	// stale-while-revalidate window after CacheTTLSec, lock TTL for
+	// rebuilding a key, and ±TTL jitter in percent
+	CacheStaleSec  int
+	CacheLockMs    int
+	CacheJitterPct int
+	// how long a lookup of a missing post is remembered
+	CacheNegativeSec int
+	// in-process LRU namespaces in front of Redis
+	CacheLocal []LocalCacheNamespace
 
 	ElasticAddr     string
 	ElasticUsername string
 	ElasticPassword string
+	// requests slower than this are logged as warnings
+	ElasticSlowMs int
+
+	StorageBackend    string
+	MediaDir          string
+	MediaMaxBytes     int
+	MediaAllowedTypes []string
+	MediaWorkers      int
+	MediaPollSec      int
+	// media never linked to a post is deleted this long after its last upload
+	MediaUnattachedHours int
+
+	WebhookWorkers     int
+	WebhookPollSec     int
+	WebhookTimeoutSec  int
+	WebhookMaxAttempts int
+
+	ActivityRetentionMonths int
+	ActivityPartitionsAhead int
+	ActivityArchiveDir      string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+
+	settings []Setting
+}
+
+// Settings returns every key Load read, in order, with its effective value
+// and source.
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+// LocalCacheNamespace bounds the in-process cache for keys starting with Prefix.
+type LocalCacheNamespace struct {
+	Prefix     string
+	MaxEntries int
+	TTL        time.Duration
 }
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// parseLocalCache reads "prefix=entries/ttl,..." such as
+// "post:=5000/30s,post:slug:=20000/10m". "off" disables the local cache.
+func parseLocalCache(v string) ([]LocalCacheNamespace, error) {
+	if v == "off" {
+		return nil, nil
 	}
-	return def
+	var out []LocalCacheNamespace
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		prefix, spec, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not prefix=entries/ttl", part)
+		}
+		size, ttl, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("%q is not prefix=entries/ttl", part)
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%q: entries must be a positive integer", part)
+		}
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q: ttl must be a positive duration such as 30s", part)
+		}
+		out = append(out, LocalCacheNamespace{Prefix: prefix, MaxEntries: n, TTL: d})
+	}
+	return out, nil
 }
 
-func getenvi(key string, def int) int {
-	if v := os.Getenv(key); v != "" {
-		var n int
-		_, _ = fmt.Sscanf(v, "%d", &n)
-		return n
+// Load reads the configuration from the environment and, when CONFIG_FILE
+// names one, a YAML or TOML file; environment variables override the file.
+// Secrets can also be read from the file named by KEY_FILE. It returns a
+// *ValidationError listing every invalid setting, not just the first.
+func Load() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+func load(env func(string) (string, bool)) (*Config, error) {
+	l := &loader{env: env}
+	if path, ok := env("CONFIG_FILE"); ok && path != "" {
+		file, err := readFile(path)
+		if err != nil {
+			return nil, &ValidationError{Problems: []Problem{{Key: "CONFIG_FILE", Msg: err.Error()}}}
+		}
+		l.file, l.path = file, path
+		l.record("CONFIG_FILE", path, SourceEnv, false)
+	}
+
+	cfg := &Config{
+		Port:          l.port("PORT", "8080"),
+		PublicBaseURL: strings.TrimRight(l.url("PUBLIC_BASE_URL", "http://localhost:8080"), "/"),
+		SiteTitle:     l.str("SITE_TITLE", "Blog"),
+		FeedSize:      l.int("FEED_SIZE", 20, 1),
+
+		HealthCheckTimeoutMs: l.int("HEALTH_CHECK_TIMEOUT_MS", 1000, 1),
+		ShutdownDrainSec:     l.int("SHUTDOWN_DRAIN_SECONDS", 5, 0),
+		TrustedProxies:       l.networks("TRUSTED_PROXIES"),
+		RequireAdminActor:    l.bool("REQUIRE_ADMIN_ACTOR", false),
+
+		LogLevel:  l.oneOf("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+		LogFormat: l.oneOf("LOG_FORMAT", "json", "json", "text"),
+
+		TracingExporter:    l.oneOf("TRACING_EXPORTER", "none", "none", "otlp", "stdout"),
+		TracingEndpoint:    l.url("TRACING_OTLP_ENDPOINT", "http://localhost:4318"),
+		TracingSampleRatio: l.float("TRACING_SAMPLE_RATIO", 1, 0, 1),
+		TracingServiceName: l.str("TRACING_SERVICE_NAME", "blog-service"),
+
+		DBHost:     l.str("DB_HOST", "localhost"),
+		DBPort:     l.port("DB_PORT", "5432"),
+		DBUser:     l.str("DB_USER", "postgres"),
+		DBPassword: l.secret("DB_PASSWORD", "postgres"),
+		DBName:     l.str("DB_NAME", "blog"),
+		DBSSLMode:  l.oneOf("DB_SSLMODE", "disable", "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		DBTimezone: l.str("DB_TIMEZONE", "UTC"),
+
+		DBMigrations:  l.oneOf("DB_MIGRATIONS", "warn", "warn", "require", "up"),
+		DBSlowQueryMs: l.int("DB_SLOW_QUERY_MS", 200, 0),
+
+		DBMaxOpenConns:       l.int("DB_MAX_OPEN_CONNS", 20, 1),
+		DBMaxIdleConns:       l.int("DB_MAX_IDLE_CONNS", 5, 0),
+		DBConnMaxLifetimeSec: l.int("DB_CONN_MAX_LIFETIME_SECONDS", 1800, 0),
+		DBConnMaxIdleSec:     l.int("DB_CONN_MAX_IDLE_SECONDS", 300, 0),
+		DBStatementTimeoutMs: l.int("DB_STATEMENT_TIMEOUT_MS", 30000, 0),
+		DBConnectTimeoutSec:  l.int("DB_CONNECT_TIMEOUT_SECONDS", 10, 0),
+		DBReplicaDSNs:        l.dsns("DB_REPLICA_DSNS"),
+		DBReplicaLagSec:      l.int("DB_REPLICA_LAG_SECONDS", 5, 1),
+
+		CacheBackend:          l.oneOf("CACHE_BACKEND", "redis", "redis", "memory"),
+		CacheMemoryMaxEntries: l.int("CACHE_MEMORY_MAX_ENTRIES", 100000, 1),
+
+		RedisAddr:        l.str("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:    l.secret("REDIS_PASSWORD", ""),
+		RedisDB:          l.int("REDIS_DB", 0, 0),
+		CacheTTLSec:      l.int("CACHE_TTL_SECONDS", 300, 1),
+		CacheStaleSec:    l.int("CACHE_STALE_SECONDS", 60, 0),
+		CacheLockMs:      l.int("CACHE_LOCK_MS", 3000, 1),
+		CacheJitterPct:   l.intRange("CACHE_TTL_JITTER_PERCENT", 10, 0, 100),
+		CacheNegativeSec: l.int("CACHE_NEGATIVE_SECONDS", 30, 0),
+		CacheLocal:       l.localCache("CACHE_LOCAL", "post:=5000/30s,post:slug:=20000/10m"),
+
+		ElasticAddr:     l.url("ELASTICSEARCH_ADDR", "http://localhost:9200"),
+		ElasticUsername: l.str("ELASTICSEARCH_USERNAME", ""),
+		ElasticPassword: l.secret("ELASTICSEARCH_PASSWORD", ""),
+		ElasticSlowMs:   l.int("ELASTICSEARCH_SLOW_MS", 500, 0),
+
+		StorageBackend:       l.oneOf("STORAGE_BACKEND", "local", "local", "s3"),
+		MediaDir:             l.str("MEDIA_DIR", "data/media"),
+		MediaMaxBytes:        l.int("MEDIA_MAX_BYTES", 10<<20, 1),
+		MediaAllowedTypes:    l.list("MEDIA_ALLOWED_TYPES", []string{"image/jpeg", "image/png", "image/gif", "image/webp"}),
+		MediaWorkers:         l.int("MEDIA_WORKERS", 2, 1),
+		MediaPollSec:         l.int("MEDIA_POLL_SECONDS", 2, 1),
+		MediaUnattachedHours: l.int("MEDIA_UNATTACHED_HOURS", 24, 1),
+
+		WebhookWorkers:     l.int("WEBHOOK_WORKERS", 2, 1),
+		WebhookPollSec:     l.int("WEBHOOK_POLL_SECONDS", 2, 1),
+		WebhookTimeoutSec:  l.int("WEBHOOK_TIMEOUT_SECONDS", 10, 1),
+		WebhookMaxAttempts: l.int("WEBHOOK_MAX_ATTEMPTS", 8, 1),
+
+		ActivityRetentionMonths: l.int("ACTIVITY_RETENTION_MONTHS", 12, 0),
+		ActivityPartitionsAhead: l.int("ACTIVITY_PARTITIONS_AHEAD", 3, 0),
+		ActivityArchiveDir:      l.str("ACTIVITY_ARCHIVE_DIR", "data/archive"),
+
+		S3Endpoint:  l.str("S3_ENDPOINT", ""),
+		S3Region:    l.str("S3_REGION", ""),
+		S3Bucket:    l.str("S3_BUCKET", "blog-media"),
+		S3AccessKey: l.secret("S3_ACCESS_KEY", ""),
+		S3SecretKey: l.secret("S3_SECRET_KEY", ""),
+		S3UseSSL:    l.bool("S3_USE_SSL", true),
+	}
+
+	if cfg.StorageBackend == "s3" {
+		if cfg.S3Endpoint == "" {
+			l.problem("S3_ENDPOINT", "required when STORAGE_BACKEND is s3")
+		}
+		if cfg.S3Bucket == "" {
+			l.problem("S3_BUCKET", "required when STORAGE_BACKEND is s3")
+		}
+	}
+	if cfg.DBMaxIdleConns > cfg.DBMaxOpenConns {
+		l.problem("DB_MAX_IDLE_CONNS", "%d is more than DB_MAX_OPEN_CONNS (%d)", cfg.DBMaxIdleConns, cfg.DBMaxOpenConns)
+	}
+	if cfg.CacheBackend == "redis" && cfg.RedisAddr == "" {
+		l.problem("REDIS_ADDR", "required when CACHE_BACKEND is redis")
+	}
+	l.checkFile()
+	if len(l.problems) > 0 {
+		return nil, &ValidationError{Problems: l.problems}
 	}
-	return def
+	cfg.settings = l.settings
+	return cfg, nil
 }
 
-func Load() *Config {
-	return &Config{
-		Port: getenv("PORT", "8080"),
-
-		DBHost:     getenv("DB_HOST", "localhost"),
-		DBPort:     getenv("DB_PORT", "5432"),
-		DBUser:     getenv("DB_USER", "postgres"),
-		DBPassword: getenv("DB_PASSWORD", "postgres"),
-		DBName:     getenv("DB_NAME", "blog"),
-		DBSSLMode:  getenv("DB_SSLMODE", "disable"),
-		DBTimezone: getenv("DB_TIMEZONE", "UTC"),
-
-		RedisAddr:     getenv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword: getenv("REDIS_PASSWORD", ""),
-		RedisDB:       getenvi("REDIS_DB", 0),
-		CacheTTLSec:   getenvi("CACHE_TTL_SECONDS", 300),
-
-		ElasticAddr:     getenv("ELASTICSEARCH_ADDR", "http://localhost:9200"),
-		ElasticUsername: getenv("ELASTICSEARCH_USERNAME", ""),
-		ElasticPassword: getenv("ELASTICSEARCH_PASSWORD", ""),
+func (l *loader) localCache(key, def string) []LocalCacheNamespace {
+	v := l.str(key, def)
+	out, err := parseLocalCache(v)
+	if err != nil {
+		l.problem(key, "%v", err)
 	}
+	return out
 } 
\ No newline at end of file
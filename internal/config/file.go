@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile parses a YAML (.yaml, .yml) or TOML (.toml) config file into
+// settings keyed like the environment. Nested tables are joined with
+// underscores and upper-cased, so
+//
+//	[db]
+//	host = "postgres"
+//
+// and `DB_HOST: postgres` both set DB_HOST. Lists become comma-separated.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("%s: config files must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	out := map[string]string{}
+	if err := flatten(out, "", doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return out, nil
+}
+
+func flatten(out map[string]string, prefix string, doc map[string]interface{}) error {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := doc[k].(type) {
+		case map[string]interface{}:
+			if err := flatten(out, key, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(key, item)
+				if err != nil {
+					return err
+				}
+				items[i] = s
+			}
+			if err := set(out, key, strings.Join(items, ",")); err != nil {
+				return err
+			}
+		default:
+			s, err := scalar(key, v)
+			if err != nil {
+				return err
+			}
+			if err := set(out, key, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// set fails when two spellings, such as db.host and DB_HOST, name the
+// same key.
+func set(out map[string]string, key, value string) error {
+	if _, dup := out[key]; dup {
+		return fmt.Errorf("%s is set twice", key)
+	}
+	out[key] = value
+	return nil
+}
+
+func scalar(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("%s: unsupported value %v", key, v)
+}
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sources of a setting, from lowest to highest precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Setting is the effective value of one key and where it came from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	// Secret settings are redacted by `app config print -redact` and may
+	// be read from the file named by KEY_FILE
+	Secret bool
+}
+
+// Problem is one invalid setting.
+type Problem struct {
+	Key string
+	Msg string
+}
+
+// ValidationError lists every invalid setting Load found.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  %s: %s", p.Key, p.Msg)
+	}
+	return b.String()
+}
+
+// loader reads typed settings from the environment and the config file,
+// recording what it read and every problem instead of stopping at the
+// first.
+type loader struct {
+	env  func(string) (string, bool)
+	file map[string]string
+	path string
+
+	settings []Setting
+	problems []Problem
+}
+
+func (l *loader) problem(key, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{Key: key, Msg: fmt.Sprintf(format, args...)})
+}
+
+// lookup returns the raw value of key and its source. Empty environment
+// variables count as unset, so `KEY=` in .env keeps the file's value or
+// the default.
+func (l *loader) lookup(key string, secret bool) (string, string, bool) {
+	if v, ok := l.env(key); ok && v != "" {
+		if f, ok := l.env(key + "_FILE"); ok && f != "" && secret {
+			l.problem(key, "set either %s or %s_FILE, not both", key, key)
+		}
+		return v, SourceEnv, true
+	}
+	if secret {
+		if f, ok := l.env(key + "_FILE"); ok && f != "" {
+			return l.readSecret(key, f), SourceEnv, true
+		}
+	}
+	if v, ok := l.file[key]; ok {
+		if _, both := l.file[key+"_FILE"]; both && secret {
+			l.problem(key, "set either %s or %s_FILE in %s, not both", key, key, l.path)
+		}
+		return v, SourceFile, true
+	}
+	if f, ok := l.file[key+"_FILE"]; ok && secret {
+		return l.readSecret(key, f), SourceFile, true
+	}
+	return "", SourceDefault, false
+}
+
+// readSecret returns the contents of the file named by KEY_FILE, without
+// the trailing newline most editors and secret stores add.
+func (l *loader) readSecret(key, path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		l.problem(key+"_FILE", "%v", err)
+		return ""
+	}
+	return strings.TrimRight(string(b), "\r\n")
+}
+
+func (l *loader) record(key, value, source string, secret bool) {
+	l.settings = append(l.settings, Setting{Key: key, Value: value, Source: source, Secret: secret})
+}
+
+func (l *loader) str(key, def string) string {
+	v, src, ok := l.lookup(key, false)
+	if !ok {
+		v = def
+	}
+	l.record(key, v, src, false)
+	return v
+}
+
+func (l *loader) secret(key, def string) string {
+	v, src, ok := l.lookup(key, true)
+	if !ok {
+		v = def
+	}
+	l.record(key, v, src, true)
+	return v
+}
+
+func (l *loader) oneOf(key, def string, allowed ...string) string {
+	v := l.str(key, def)
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	l.problem(key, "%q is not one of %s", v, strings.Join(allowed, ", "))
+	return def
+}
+
+// int reads an integer no smaller than min.
+func (l *loader) int(key string, def, min int) int {
+	n, ok := l.parseInt(key, def)
+	if ok && n < min {
+		l.problem(key, "%d is less than %d", n, min)
+		return def
+	}
+	return n
+}
+
+func (l *loader) intRange(key string, def, min, max int) int {
+	n, ok := l.parseInt(key, def)
+	if ok && (n < min || n > max) {
+		l.problem(key, "%d is not between %d and %d", n, min, max)
+		return def
+	}
+	return n
+}
+
+// parseInt returns def and false when key is unset or malformed.
+func (l *loader) parseInt(key string, def int) (int, bool) {
+	v, src, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strconv.Itoa(def), src, false)
+		return def, false
+	}
+	l.record(key, v, src, false)
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		l.problem(key, "%q is not an integer", v)
+		return def, false
+	}
+	return n, true
+}
+
+func (l *loader) float(key string, def, min, max float64) float64 {
+	v, src, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strconv.FormatFloat(def, 'g', -1, 64), src, false)
+		return def
+	}
+	l.record(key, v, src, false)
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	switch {
+	case err != nil:
+		l.problem(key, "%q is not a number", v)
+		return def
+	case f < min || f > max:
+		l.problem(key, "%g is not between %g and %g", f, min, max)
+		return def
+	}
+	return f
+}
+
+func (l *loader) bool(key string, def bool) bool {
+	v, src, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strconv.FormatBool(def), src, false)
+		return def
+	}
+	l.record(key, v, src, false)
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	}
+	l.problem(key, "%q is not a boolean (true, false, 1, 0, yes, no)", v)
+	return def
+}
+
+// list reads a comma-separated list, dropping empty items.
+func (l *loader) list(key string, def []string) []string {
+	v, src, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strings.Join(def, ","), src, false)
+		return def
+	}
+	l.record(key, v, src, false)
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// networks reads a comma-separated list of IP addresses and CIDR ranges.
+func (l *loader) networks(key string) []string {
+	var out []string
+	for _, v := range l.list(key, nil) {
+		if net.ParseIP(v) == nil {
+			if _, _, err := net.ParseCIDR(v); err != nil {
+				l.problem(key, "%q is not an IP address or CIDR range", v)
+				continue
+			}
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// dsns reads a secret comma-separated list of postgres:// URLs. Commas in
+// passwords must be escaped as %2C. Problems don't quote the URLs, which
+// hold passwords.
+func (l *loader) dsns(key string) []string {
+	v := l.secret(key, "")
+	var out []string
+	for i, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		u, err := url.Parse(part)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			l.problem(key, "entry %d is not a postgres:// URL", i+1)
+			continue
+		}
+		out = append(out, part)
+	}
+	return out
+}
+
+// url reads an absolute http or https URL.
+func (l *loader) url(key, def string) string {
+	v := l.str(key, def)
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		l.problem(key, "%q is not an http(s) URL", v)
+	}
+	return v
+}
+
+// port reads a TCP port number, kept as a string.
+func (l *loader) port(key, def string) string {
+	v := l.str(key, def)
+	if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+		l.problem(key, "%q is not a port number", v)
+	}
+	return v
+}
+
+// checkFile reports keys in the config file that Load never read, which
+// are most likely typos.
+func (l *loader) checkFile() {
+	known := map[string]bool{}
+	for _, s := range l.settings {
+		known[s.Key] = true
+		if s.Secret {
+			known[s.Key+"_FILE"] = true
+		}
+	}
+	var unknown []string
+	for key := range l.file {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		l.problem(key, "unknown setting in %s", l.path)
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activity_logs is range-partitioned by month on logged_at. Partitions are
+// named activity_logs_YYYY_MM and cover [first of month, first of next month)
+// in UTC. Migration 0002 creates the table; the retention worker keeps
+// partitions ahead of time. Rows outside all of them go to the default
+// partition (migration 0005) until their month's partition is created.
+
+const activityDefaultPartition = "activity_logs_default"
+
+var activityPartitionName = regexp.MustCompile(`^activity_logs_(\d{4})_(\d{2})$`)
+
+// ActivityPartition is one monthly partition. Detached partitions are left
+// behind by a retention run that failed between detaching and dropping.
+type ActivityPartition struct {
+	Name     string
+	Month    time.Time
+	Attached bool
+}
+
+// ActivityPartitionName returns the partition holding rows logged in month.
+func ActivityPartitionName(month time.Time) string {
+	return fmt.Sprintf("activity_logs_%04d_%02d", month.Year(), int(month.Month()))
+}
+
+// MonthStart truncates t to the first instant of its month in UTC.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// EnsureActivityPartitions creates any missing monthly partitions from the
+// month of from through the month of to, and for every month that has rows
+// in the default partition, moving those rows into them.
+func (d *Database) EnsureActivityPartitions(ctx context.Context, from, to time.Time) error {
+	g := d.Gorm.WithContext(ctx)
+	var stray []struct{ Month time.Time }
+	err := g.Raw(`SELECT DISTINCT date_trunc('month', logged_at AT TIME ZONE 'UTC') AS month FROM ` + activityDefaultPartition).
+		Scan(&stray).Error
+	if err != nil {
+		return err
+	}
+	var months []time.Time
+	for m := MonthStart(from); !m.After(MonthStart(to)); m = m.AddDate(0, 1, 0) {
+		months = append(months, m)
+	}
+	for _, s := range stray {
+		months = append(months, MonthStart(s.Month))
+	}
+	for _, m := range months {
+		if err := g.Transaction(func(tx *gorm.DB) error { return ensurePartition(tx, m) }); err != nil {
+			return fmt.Errorf("%s: %w", ActivityPartitionName(m), err)
+		}
+	}
+	return nil
+}
+
+// ensurePartition creates month's partition unless it exists. Postgres
+// refuses to add a partition while the default one holds rows in its range,
+// so it is built detached, filled with those rows and then attached.
+func ensurePartition(tx *gorm.DB, month time.Time) error {
+	name := ActivityPartitionName(month)
+	var exists bool
+	if err := tx.Raw(`SELECT to_regclass(?) IS NOT NULL`, name).Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	from, to := month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339)
+	for _, stmt := range []string{
+		// attaching locks the default partition anyway; taking the lock
+		// first keeps new rows for this month out of it meanwhile
+		`LOCK TABLE ` + activityDefaultPartition + ` IN ACCESS EXCLUSIVE MODE`,
+		fmt.Sprintf(`CREATE TABLE %s (LIKE activity_logs INCLUDING DEFAULTS)`, name),
+		fmt.Sprintf(`WITH moved AS (DELETE FROM %s WHERE logged_at >= '%s' AND logged_at < '%s' RETURNING *)
+			INSERT INTO %s SELECT * FROM moved`, activityDefaultPartition, from, to, name),
+		fmt.Sprintf(`ALTER TABLE activity_logs ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`, name, from, to),
+	} {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActivityPartitions lists monthly partitions, attached or not, oldest first.
+func (d *Database) ActivityPartitions(ctx context.Context) ([]ActivityPartition, error) {
+	var rows []struct {
+		Name     string
+		Attached bool
+	}
+	err := d.Gorm.WithContext(ctx).Raw(`
+		SELECT c.relname AS name,
+		       EXISTS (SELECT 1 FROM pg_inherits i WHERE i.inhrelid = c.oid AND i.inhparent = to_regclass('activity_logs')) AS attached
+		FROM pg_class c
+		WHERE c.relkind = 'r' AND c.relname LIKE 'activity\_logs\_%' AND c.relnamespace = current_schema()::regnamespace
+		ORDER BY c.relname`).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	var out []ActivityPartition
+	for _, r := range rows {
+		m := activityPartitionName.FindStringSubmatch(r.Name)
+		if m == nil {
+			continue
+		}
+		month, err := time.Parse("2006-01", m[1]+"-"+m[2])
+		if err != nil {
+			continue
+		}
+		out = append(out, ActivityPartition{Name: r.Name, Month: month, Attached: r.Attached})
+	}
+	return out, nil
+}
+
+// DetachActivityPartition takes a partition out of activity_logs so it no
+// longer shows up in queries and can be archived without holding locks on
+// the parent.
+func (d *Database) DetachActivityPartition(ctx context.Context, name string) error {
+	if !activityPartitionName.MatchString(name) {
+		return fmt.Errorf("not an activity partition: %q", name)
+	}
+	return d.Gorm.WithContext(ctx).Exec(`ALTER TABLE activity_logs DETACH PARTITION ` + name).Error
+}
+
+func (d *Database) DropActivityPartition(ctx context.Context, name string) error {
+	if !activityPartitionName.MatchString(name) {
+		return fmt.Errorf("not an activity partition: %q", name)
+	}
+	return d.Gorm.WithContext(ctx).Exec(`DROP TABLE IF EXISTS ` + name).Error
+}
+
+// noStatementTimeout lifts DB_STATEMENT_TIMEOUT_MS for the rest of the
+// transaction it runs in.
+const noStatementTimeout = `SET LOCAL statement_timeout = 0`
+
+// WithoutStatementTimeout runs fn in a transaction on the primary that
+// DB_STATEMENT_TIMEOUT_MS doesn't apply to, for maintenance statements such
+// as exporting a whole partition.
+func (d *Database) WithoutStatementTimeout(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return d.Gorm.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(noStatementTimeout).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// TryAdvisoryLock runs fn while holding the session-level advisory lock key,
+// on a dedicated connection. It reports false without running fn when
+// another session holds the lock.
+func (d *Database) TryAdvisoryLock(ctx context.Context, key int64, fn func() error) (bool, error) {
+	conn, err := d.SQL.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+	return true, fn()
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDatabase connects to TEST_DATABASE_URL, a scratch database the test
+// may migrate and write to, and skips the test when it isn't set.
+func testDatabase(t *testing.T) *Database {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	g, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := g.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	d := &Database{Gorm: g, SQL: sqlDB}
+	if _, err := d.MigrateUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// A row logged in a month without a partition is kept in the default
+// partition, and moves to its month's partition once that is created.
+func TestActivityOutsidePartitions(t *testing.T) {
+	d := testDatabase(t)
+	ctx := context.Background()
+	month := time.Date(2001, 2, 1, 0, 0, 0, 0, time.UTC)
+	name := ActivityPartitionName(month)
+	t.Cleanup(func() {
+		d.Gorm.Exec(`DELETE FROM activity_logs WHERE post_id = -1`)
+		d.Gorm.Exec(`DROP TABLE IF EXISTS ` + name)
+	})
+
+	err := d.Gorm.Exec(`INSERT INTO activity_logs (action, post_id, logged_at) VALUES ('new_post', -1, ?)`,
+		month.AddDate(0, 0, 14)).Error
+	if err != nil {
+		t.Fatalf("insert outside the partitions: %v", err)
+	}
+	count := func(table string) int64 {
+		t.Helper()
+		var n int64
+		if err := d.Gorm.Table(table).Where("post_id = -1").Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(activityDefaultPartition); n != 1 {
+		t.Fatalf("default partition has %d rows, want 1", n)
+	}
+
+	now := time.Now()
+	if err := d.EnsureActivityPartitions(ctx, now, now); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(activityDefaultPartition); n != 0 {
+		t.Errorf("default partition still has %d rows", n)
+	}
+	if n := count(name); n != 1 {
+		t.Errorf("%s has %d rows, want 1", name, n)
+	}
+	parts, err := d.ActivityPartitions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range parts {
+		if p.Name == name && !p.Attached {
+			t.Errorf("%s is not attached", name)
+		}
+	}
+}
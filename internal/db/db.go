@@ -3,10 +3,16 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
 	"github.com/example/blog-service/internal/config"
 )
@@ -14,12 +20,19 @@ import (
 type Database struct {
 	Gorm *gorm.DB
 	SQL  *sql.DB
+	// read replicas, used round-robin by Reader; empty without DB_REPLICA_DSNS
+	Replicas []*gorm.DB
+
+	next atomic.Uint64
+	// replicas that failed their last ping; see WatchReplicas
+	down sync.Map
 }
 
+// Connect opens the primary and any replicas with the pool limits and
+// session timeouts from cfg. Replicas are not pinged, so one that is down
+// doesn't stop startup; WatchReplicas takes it out of rotation.
 func Connect(cfg *config.Config) (*Database, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=%s",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode, cfg.DBTimezone)
-	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Info)})
+	gormDB, err := open(PrimaryDSN(cfg), cfg, false)
 	if err != nil {
 		return nil, err
 	}
@@ -27,20 +40,100 @@ func Connect(cfg *config.Config) (*Database, error) {
 	if err != nil {
 		return nil, err
 	}
-	sqlDB.SetMaxOpenConns(20)
-	sqlDB.SetMaxIdleConns(5)
-	return &Database{Gorm: gormDB, SQL: sqlDB}, nil
+	database := &Database{Gorm: gormDB, SQL: sqlDB}
+	for i, dsn := range cfg.DBReplicaDSNs {
+		dsn, err := ReplicaDSN(dsn, cfg)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		replica, err := open(dsn, cfg, true)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		database.Replicas = append(database.Replicas, replica)
+	}
+	return database, nil
 }
 
-func (d *Database) AutoMigrate(modelsToMigrate ...interface{}) error {
-	return d.Gorm.AutoMigrate(modelsToMigrate...)
+func open(dsn string, cfg *config.Config, replica bool) (*gorm.DB, error) {
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:               newGormLogger(slog.Default().With("component", "gorm"), time.Duration(cfg.DBSlowQueryMs)*time.Millisecond),
+		DisableAutomaticPing: replica,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := registerTracing(gormDB); err != nil {
+		return nil, err
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DBConnMaxLifetimeSec) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.DBConnMaxIdleSec) * time.Second)
+	return gormDB, nil
 }
 
-func (d *Database) EnsureGINIndexOnTags() error {
-	return d.Gorm.Exec("CREATE INDEX IF NOT EXISTS idx_posts_tags_gin ON posts USING GIN (tags);").Error
+// PrimaryDSN builds a postgres:// URL from the DB_* settings. Every part is
+// escaped, so passwords may contain spaces, quotes or '@'.
+func PrimaryDSN(cfg *config.Config) string {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:   net.JoinHostPort(cfg.DBHost, cfg.DBPort),
+		Path:   "/" + cfg.DBName,
+	}
+	q := url.Values{}
+	q.Set("sslmode", cfg.DBSSLMode)
+	q.Set("TimeZone", cfg.DBTimezone)
+	sessionParams(q, cfg)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// ReplicaDSN adds the session timeouts and time zone of cfg to a replica's
+// postgres:// URL, unless the URL sets them itself.
+func ReplicaDSN(dsn string, cfg *config.Config) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		// the error would echo the password
+		return "", fmt.Errorf("not a postgres:// URL")
+	}
+	q := u.Query()
+	defaults := url.Values{}
+	defaults.Set("TimeZone", cfg.DBTimezone)
+	sessionParams(defaults, cfg)
+	for k, v := range defaults {
+		if !q.Has(k) {
+			q[k] = v
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// sessionParams sets the connect and statement timeouts; 0 leaves the
+// server's defaults.
+func sessionParams(q url.Values, cfg *config.Config) {
+	if cfg.DBConnectTimeoutSec > 0 {
+		q.Set("connect_timeout", strconv.Itoa(cfg.DBConnectTimeoutSec))
+	}
+	if cfg.DBStatementTimeoutMs > 0 {
+		q.Set("statement_timeout", strconv.Itoa(cfg.DBStatementTimeoutMs))
+	}
 }
 
 func (d *Database) Close() error {
+	for _, r := range d.Replicas {
+		if sqlDB, err := r.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
 	if d.SQL != nil {
 		return d.SQL.Close()
 	}
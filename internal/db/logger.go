@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger sends GORM's logs to slog. Failed queries are errors, queries
+// slower than slow are warnings, and the rest are debug records, so SQL
+// only shows up at LOG_LEVEL=debug. Statements are logged with their
+// placeholders, never their parameters, which can hold personal data.
+type gormLogger struct {
+	log   *slog.Logger
+	level logger.LogLevel
+	slow  time.Duration
+}
+
+func newGormLogger(log *slog.Logger, slow time.Duration) logger.Interface {
+	return &gormLogger{log: log, level: logger.Info, slow: slow}
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	c := *l
+	c.level = level
+	return &c
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.log.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.log.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.log.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// ParamsFilter makes GORM hand Trace the SQL without its parameters
+// interpolated.
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	level, msg := slog.LevelDebug, "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		level, msg = slog.LevelError, "query failed"
+	case l.slow > 0 && elapsed > l.slow && l.level >= logger.Warn:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+	if !l.log.Enabled(ctx, level) {
+		return
+	}
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.log.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s: want version %d; versions must have no gaps", m.Version, m.Name, i+1)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	cases := []struct {
+		name    string
+		files   []string
+		want    []int
+		wantErr string
+	}{
+		{name: "ordered", files: []string{"0002_b.up.sql", "0002_b.down.sql", "0001_a.up.sql", "0001_a.down.sql"}, want: []int{1, 2}},
+		{name: "missing down", files: []string{"0001_a.up.sql"}, wantErr: "needs both"},
+		{name: "bad name", files: []string{"init.sql"}, wantErr: "name is not"},
+		{name: "two names", files: []string{"0001_a.up.sql", "0001_b.down.sql"}, wantErr: "two names"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, f := range tc.files {
+				fsys["m/"+f] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+			}
+			got, err := loadMigrations(fsys, "m")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int
+			for _, m := range got {
+				versions = append(versions, m.Version)
+			}
+			if len(versions) != len(tc.want) || versions[0] != tc.want[0] || versions[len(versions)-1] != tc.want[len(tc.want)-1] {
+				t.Errorf("versions = %v, want %v", versions, tc.want)
+			}
+		})
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"0001_a.up.sql", "0001_a.down.sql"} {
+		if err := os.WriteFile(dir+"/"+f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	up, down, err := CreateMigration(dir, "add_views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(up, "0002_add_views.up.sql") || !strings.HasSuffix(down, "0002_add_views.down.sql") {
+		t.Errorf("created %s, %s", up, down)
+	}
+	if _, _, err := CreateMigration(dir, "add-views"); err == nil {
+		t.Error("accepted a name with a dash")
+	}
+	all, err := loadMigrations(os.DirFS(dir), ".")
+	if err != nil || len(all) != 2 {
+		t.Errorf("after create: %d migrations, %v", len(all), err)
+	}
+}
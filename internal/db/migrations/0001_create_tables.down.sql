@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS post_media;
+DROP TABLE IF EXISTS post_slug_history;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS media_renditions;
+DROP TABLE IF EXISTS media;
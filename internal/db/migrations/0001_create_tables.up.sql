@@ -0,0 +1,171 @@
+-- Tables as GORM AutoMigrate used to create them. Everything is IF NOT EXISTS,
+-- and posts gets every column added since the first release, so databases set
+-- up by AutoMigrate adopt this migration. Existing posts are given a slug and
+-- HTML here; run `app reindex` afterwards so search sees them.
+
+CREATE TABLE IF NOT EXISTS media (
+    id            bigserial PRIMARY KEY,
+    hash          char(64) NOT NULL,
+    storage_key   varchar(255) NOT NULL,
+    mime_type     varchar(100) NOT NULL,
+    size          bigint NOT NULL,
+    original_name varchar(255),
+    created_at    timestamptz,
+    status        varchar(20) NOT NULL DEFAULT 'pending',
+    attempts      bigint NOT NULL DEFAULT 0,
+    claimed_at    timestamptz,
+    error         text,
+    width         bigint,
+    height        bigint,
+    blur_hash     varchar(64)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_hash ON media (hash);
+CREATE INDEX IF NOT EXISTS idx_media_status ON media (status);
+
+CREATE TABLE IF NOT EXISTS media_renditions (
+    id          bigserial PRIMARY KEY,
+    media_id    bigint NOT NULL,
+    name        varchar(50) NOT NULL,
+    format      varchar(10) NOT NULL,
+    storage_key varchar(255) NOT NULL,
+    mime_type   varchar(100) NOT NULL,
+    hash        char(64) NOT NULL,
+    width       bigint,
+    height      bigint,
+    size        bigint,
+    created_at  timestamptz,
+    CONSTRAINT fk_media_renditions FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_rendition ON media_renditions (media_id, name, format);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id                   bigserial PRIMARY KEY,
+    title                varchar(255) NOT NULL,
+    slug                 varchar(255),
+    content              text NOT NULL,
+    content_format       varchar(20) NOT NULL DEFAULT 'plain',
+    content_html         text,
+    tags                 text[],
+    cover_media_id       bigint,
+    author               varchar(100),
+    status               varchar(20) NOT NULL DEFAULT 'published',
+    published_at         timestamptz,
+    excerpt              text,
+    word_count           bigint NOT NULL DEFAULT 0,
+    reading_time_minutes bigint NOT NULL DEFAULT 0,
+    toc                  jsonb,
+    created_at           timestamptz,
+    updated_at           timestamptz,
+    CONSTRAINT fk_posts_cover_media FOREIGN KEY (cover_media_id) REFERENCES media (id) ON DELETE SET NULL
+);
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS slug                 varchar(255),
+    ADD COLUMN IF NOT EXISTS content_format       varchar(20) NOT NULL DEFAULT 'plain',
+    ADD COLUMN IF NOT EXISTS content_html         text,
+    ADD COLUMN IF NOT EXISTS cover_media_id       bigint
+        CONSTRAINT fk_posts_cover_media REFERENCES media (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS author               varchar(100),
+    ADD COLUMN IF NOT EXISTS status               varchar(20) NOT NULL DEFAULT 'published',
+    ADD COLUMN IF NOT EXISTS published_at         timestamptz,
+    ADD COLUMN IF NOT EXISTS excerpt              text,
+    ADD COLUMN IF NOT EXISTS word_count           bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS reading_time_minutes bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS toc                  jsonb;
+
+-- Slugs for posts from before slugs existed: the ASCII letters and digits of
+-- the title, as slug.Make makes them minus transliteration. A base that two
+-- posts share, that ends in -<digits> or that is taken gets -<id> appended.
+-- The id makes those unique, and the bases kept as they are never end in
+-- -<digits>, so the two kinds can't collide.
+WITH base AS (
+    SELECT id, coalesce(nullif(rtrim(left(btrim(regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g'), '-'), 90), '-'), ''), 'post') AS slug
+    FROM posts
+    WHERE slug IS NULL
+), named AS (
+    SELECT id, CASE
+               WHEN count(*) OVER (PARTITION BY slug) = 1 AND slug !~ '-[0-9]+$'
+                    AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.slug = base.slug) THEN slug
+               ELSE slug || '-' || id
+           END AS slug
+    FROM base
+)
+UPDATE posts SET slug = named.slug FROM named WHERE posts.id = named.id;
+
+-- HTML, word count and reading time of plain-text posts, as markup.Render and
+-- markup.Summarize make them: one escaped <p> per paragraph separated by a
+-- blank line, with <br> for single line breaks. Excerpts and tables of
+-- contents are filled in when a post is next edited.
+UPDATE posts SET
+    content_html = coalesce((
+        SELECT string_agg('<p>' || replace(
+                   replace(replace(replace(replace(replace(para, '&', '&amp;'), '''', '&#39;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'),
+                   E'\n', '<br>') || E'</p>\n', '' ORDER BY n)
+        FROM (
+            SELECT btrim(t.para, E' \t\r\n') AS para, t.n
+            FROM regexp_split_to_table(replace(content, E'\r\n', E'\n'), E'\n\n') WITH ORDINALITY AS t (para, n)
+        ) paras
+        WHERE para <> ''
+    ), ''),
+    word_count = coalesce(array_length(regexp_split_to_array(nullif(btrim(content, E' \t\r\n'), ''), '\s+'), 1), 0),
+    reading_time_minutes = (coalesce(array_length(regexp_split_to_array(nullif(btrim(content, E' \t\r\n'), ''), '\s+'), 1), 0) + 199) / 200
+WHERE content_html IS NULL AND content_format = 'plain';
+
+UPDATE posts SET published_at = created_at WHERE published_at IS NULL AND status = 'published';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts (slug);
+CREATE INDEX IF NOT EXISTS idx_posts_cover_media_id ON posts (cover_media_id);
+CREATE INDEX IF NOT EXISTS idx_posts_author ON posts (author);
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts (status);
+CREATE INDEX IF NOT EXISTS idx_posts_published_at ON posts (published_at);
+CREATE INDEX IF NOT EXISTS idx_posts_tags_gin ON posts USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS post_slug_history (
+    id         bigserial PRIMARY KEY,
+    post_id    bigint NOT NULL,
+    slug       varchar(255) NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_post_slug_history_post_id ON post_slug_history (post_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_slug_history_slug ON post_slug_history (slug);
+
+CREATE TABLE IF NOT EXISTS post_media (
+    post_id    bigint NOT NULL,
+    media_id   bigint NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (post_id, media_id),
+    CONSTRAINT fk_post_media_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_media_media FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE RESTRICT
+);
+CREATE INDEX IF NOT EXISTS idx_post_media_media_id ON post_media (media_id);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         bigserial PRIMARY KEY,
+    url        text NOT NULL,
+    events     text[] NOT NULL,
+    secret     varchar(255) NOT NULL,
+    active     boolean NOT NULL DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              bigserial PRIMARY KEY,
+    webhook_id      bigint NOT NULL,
+    event           varchar(50) NOT NULL,
+    payload         jsonb NOT NULL,
+    status          varchar(20) NOT NULL DEFAULT 'pending',
+    attempts        bigint NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    claimed_at      timestamptz,
+    response_code   bigint,
+    response_body   text,
+    error           text,
+    duration_ms     bigint,
+    delivered_at    timestamptz,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,4 @@
+-- Drops the activity log with all attached partitions. Export anything worth
+-- keeping first (activity-retention archives detached partitions).
+DROP TABLE IF EXISTS activity_logs;
+DROP SEQUENCE IF EXISTS activity_logs_id_seq;
@@ -0,0 +1,71 @@
+-- activity_logs is range-partitioned by month on logged_at (see
+-- internal/db/activity_partitions.go). A plain table left by older versions
+-- is converted, rows included; partitions are created for its rows and from
+-- the current month three months ahead. The retention worker keeps creating
+-- partitions after that.
+DO $$
+DECLARE
+    kind  "char";
+    first timestamp;
+    m     timestamp;
+BEGIN
+    SELECT relkind INTO kind FROM pg_class WHERE oid = to_regclass('activity_logs');
+    IF kind = 'p' THEN
+        RETURN;
+    END IF;
+
+    IF kind = 'r' THEN
+        ALTER TABLE activity_logs RENAME TO activity_logs_unpartitioned;
+        -- tables from before the audit columns existed lack some of them
+        ALTER TABLE activity_logs_unpartitioned
+            ADD COLUMN IF NOT EXISTS actor_id varchar(100),
+            ADD COLUMN IF NOT EXISTS ip varchar(45),
+            ADD COLUMN IF NOT EXISTS user_agent text,
+            ADD COLUMN IF NOT EXISTS request_id varchar(100),
+            ADD COLUMN IF NOT EXISTS changes jsonb;
+    END IF;
+
+    CREATE SEQUENCE IF NOT EXISTS activity_logs_id_seq;
+    CREATE TABLE activity_logs (
+        id         bigint NOT NULL DEFAULT nextval('activity_logs_id_seq'),
+        action     varchar(50) NOT NULL,
+        post_id    bigint NOT NULL,
+        actor_id   varchar(100),
+        ip         varchar(45),
+        user_agent text,
+        request_id varchar(100),
+        changes    jsonb,
+        logged_at  timestamptz NOT NULL DEFAULT now()
+    ) PARTITION BY RANGE (logged_at);
+
+    first := date_trunc('month', now() AT TIME ZONE 'UTC');
+    IF kind = 'r' THEN
+        SELECT least(first, date_trunc('month', min(logged_at) AT TIME ZONE 'UTC'))
+        INTO first FROM activity_logs_unpartitioned;
+    END IF;
+    m := first;
+    WHILE m <= date_trunc('month', now() AT TIME ZONE 'UTC') + interval '3 months' LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF activity_logs FOR VALUES FROM (%L) TO (%L)',
+            'activity_logs_' || to_char(m, 'YYYY_MM'), m::text || '+00', (m + interval '1 month')::text || '+00');
+        m := m + interval '1 month';
+    END LOOP;
+
+    IF kind = 'r' THEN
+        INSERT INTO activity_logs (id, action, post_id, actor_id, ip, user_agent, request_id, changes, logged_at)
+        SELECT id, action, post_id, actor_id, ip, user_agent, request_id, changes, logged_at FROM activity_logs_unpartitioned;
+    END IF;
+
+    -- hand the sequence over before the old table (which may own it) goes
+    ALTER SEQUENCE activity_logs_id_seq OWNED BY activity_logs.id;
+    IF kind = 'r' THEN
+        DROP TABLE activity_logs_unpartitioned;
+    END IF;
+
+    ALTER TABLE activity_logs ADD PRIMARY KEY (id, logged_at);
+    CREATE INDEX idx_activity_logs_post_id ON activity_logs (post_id);
+    CREATE INDEX idx_activity_logs_action ON activity_logs (action);
+    CREATE INDEX idx_activity_logs_actor_id ON activity_logs (actor_id);
+    CREATE INDEX idx_activity_logs_request_id ON activity_logs (request_id);
+    CREATE INDEX idx_activity_logs_logged_at ON activity_logs (logged_at);
+END
+$$;
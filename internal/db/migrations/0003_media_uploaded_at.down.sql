@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_media_uploaded_at;
+ALTER TABLE media DROP COLUMN IF EXISTS uploaded_at;
@@ -0,0 +1,5 @@
+-- When the bytes of a media row were last uploaded. Garbage collection spares
+-- recent uploads, which may have been handed to a client that hasn't linked
+-- them yet, and a periodic sweep removes media that was never linked.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS uploaded_at timestamptz NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS idx_media_uploaded_at ON media (uploaded_at);
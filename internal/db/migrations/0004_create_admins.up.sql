@@ -0,0 +1,7 @@
+-- Actors allowed to write when REQUIRE_ADMIN_ACTOR is on, keyed by the
+-- X-Actor-ID the gateway sends. `app user create-admin` adds them.
+CREATE TABLE IF NOT EXISTS admins (
+    id         varchar(100) PRIMARY KEY,
+    name       varchar(255),
+    created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+-- Refuses to drop rows: run `app activity retention`, which creates
+-- partitions for them, first.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM activity_logs_default) THEN
+        RAISE EXCEPTION 'activity_logs_default still has rows; run "app activity retention" first';
+    END IF;
+END
+$$;
+DROP TABLE IF EXISTS activity_logs_default;
@@ -0,0 +1,5 @@
+-- Rows logged outside every monthly partition (a clock far off, a backdated
+-- import, a retention worker that hasn't run in months) land here instead of
+-- failing the write that logged them. Creating a month's partition moves its
+-- rows out (see internal/db/activity_partitions.go).
+CREATE TABLE IF NOT EXISTS activity_logs_default PARTITION OF activity_logs DEFAULT;
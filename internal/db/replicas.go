@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// replicaPingInterval is how long a replica that went down keeps getting
+// reads, at most.
+const replicaPingInterval = 5 * time.Second
+
+type primaryKey struct{}
+
+// WithPrimary returns a copy of ctx whose reads go to the primary even when
+// they would normally use a replica. Use it to read back what was just
+// written, which a lagging replica may not have yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// ReadsPrimary reports whether ctx came from WithPrimary.
+func ReadsPrimary(ctx context.Context) bool {
+	return ctx.Value(primaryKey{}) != nil
+}
+
+// Reader returns the connection for a read that tolerates replication lag:
+// the next healthy replica in turn, or the primary when there are none, all
+// of them failed their last WatchReplicas ping, or ctx came from
+// WithPrimary.
+func (d *Database) Reader(ctx context.Context) *gorm.DB {
+	if len(d.Replicas) == 0 || ReadsPrimary(ctx) {
+		return d.Gorm
+	}
+	for range d.Replicas {
+		r := d.Replicas[(d.next.Add(1)-1)%uint64(len(d.Replicas))]
+		if _, down := d.down.Load(r); !down {
+			return r
+		}
+	}
+	return d.Gorm
+}
+
+// WatchReplicas pings every replica every few seconds until ctx is done. A
+// replica that fails its ping gets no reads until it answers again.
+func (d *Database) WatchReplicas(ctx context.Context, timeout time.Duration) {
+	if len(d.Replicas) == 0 {
+		return
+	}
+	t := time.NewTicker(replicaPingInterval)
+	defer t.Stop()
+	for {
+		d.CheckReplicas(ctx, timeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// CheckReplicas pings every replica once and updates which ones Reader
+// skips.
+func (d *Database) CheckReplicas(ctx context.Context, timeout time.Duration) {
+	for i, r := range d.Replicas {
+		err := ping(ctx, r, timeout)
+		if ctx.Err() != nil {
+			return
+		}
+		_, wasDown := d.down.Load(r)
+		switch {
+		case err != nil && !wasDown:
+			d.down.Store(r, struct{}{})
+			slog.WarnContext(ctx, "replica down, reading from the others or the primary", "replica", i+1, "error", err)
+		case err == nil && wasDown:
+			d.down.Delete(r)
+			slog.InfoContext(ctx, "replica back up", "replica", i+1)
+		}
+	}
+}
+
+func ping(ctx context.Context, g *gorm.DB, timeout time.Duration) error {
+	sqlDB, err := g.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/tracing"
+)
+
+const spanKey = "otel:span"
+
+// statementSpan is the span of the running statement and the context to
+// restore when it ends, so statements sharing a session (a transaction)
+// become siblings rather than nesting.
+type statementSpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+// registerTracing adds GORM callbacks that wrap every statement in a client
+// span, a child of the span in the statement's context. The SQL is recorded
+// without its arguments.
+func registerTracing(g *gorm.DB) error {
+	cb := g.Callback()
+	for _, p := range []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	} {
+		if err := p.before("otel:before_"+p.name, startSpan(p.name)); err != nil {
+			return err
+		}
+		if err := p.after("otel:after_"+p.name, endSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		ctx, span := tracing.Tracer().Start(parent, "db."+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(op)))
+		tx.Statement.Context = ctx
+		tx.InstanceSet(spanKey, statementSpan{span, parent})
+	}
+}
+
+func endSpan(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	st := v.(statementSpan)
+	span := st.span
+	tx.Statement.Context = st.parent
+	defer span.End()
+	if tx.Statement.Table != "" {
+		span.SetAttributes(semconv.DBCollectionName(tx.Statement.Table))
+	}
+	span.SetAttributes(
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if err := tx.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,46 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+)
+
+// Admins implements the admin repository.
+type Admins struct {
+	calls
+
+	mu     sync.Mutex
+	admins map[string]models.Admin
+}
+
+func NewAdmins() *Admins {
+	return &Admins{admins: make(map[string]models.Admin)}
+}
+
+func (r *Admins) Create(ctx context.Context, a *models.Admin) error {
+	if err := r.call("Create"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.admins[a.ID]; ok {
+		return repository.ErrAdminExists
+	}
+	a.CreatedAt = time.Now()
+	r.admins[a.ID] = *a
+	return nil
+}
+
+func (r *Admins) Exists(ctx context.Context, id string) (bool, error) {
+	if err := r.call("Exists"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.admins[id]
+	return ok, nil
+}
@@ -0,0 +1,60 @@
+// Package fakes provides thread-safe in-memory stand-ins for the
+// dependencies of the service layer, for unit tests. Missing rows are
+// reported with gorm.ErrRecordNotFound and empty listings as empty slices,
+// like the real repositories. The tx argument is ignored: writes apply
+// immediately and are not rolled back.
+package fakes
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Tx is a Transactor that runs fn without a transaction.
+type Tx struct{}
+
+func (Tx) Transaction(fn func(tx *gorm.DB) error) error { return fn(nil) }
+
+// calls counts method calls and holds injected failures.
+type calls struct {
+	mu    sync.Mutex
+	n     map[string]int
+	fails map[string]error
+}
+
+// Calls returns how many times method has been called.
+func (c *calls) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n[method]
+}
+
+// FailOn makes every later call of method return err; nil clears it.
+func (c *calls) FailOn(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fails == nil {
+		c.fails = make(map[string]error)
+	}
+	c.fails[method] = err
+}
+
+func (c *calls) call(method string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n == nil {
+		c.n = make(map[string]int)
+	}
+	c.n[method]++
+	return c.fails[method]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+// Media implements the media repository, including post-media links.
+// Uploaded media is created pending; Add seeds processed media directly,
+// uploaded long ago unless it has an UploadedAt.
+type Media struct {
+	calls
+
+	mu     sync.Mutex
+	lastID uint
+	media  map[uint]models.Media
+	links  map[uint]map[uint]bool // post ID -> media IDs
+}
+
+func NewMedia() *Media {
+	return &Media{media: make(map[uint]models.Media), links: make(map[uint]map[uint]bool)}
+}
+
+// Add stores m, ready unless it has a status, and returns its ID.
+func (r *Media) Add(m models.Media) uint {
+	if m.Status == "" {
+		m.Status = models.MediaReady
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.insert(m)
+}
+
+func (r *Media) insert(m models.Media) uint {
+	r.lastID++
+	m.ID = r.lastID
+	m.CreatedAt = time.Now()
+	for i := range m.Renditions {
+		m.Renditions[i].MediaID = m.ID
+	}
+	r.media[m.ID] = m
+	return m.ID
+}
+
+// LockHash records the call; tests don't upload concurrently.
+func (r *Media) LockHash(ctx context.Context, tx *gorm.DB, hash string) error {
+	return r.call("LockHash")
+}
+
+func (r *Media) Create(ctx context.Context, tx *gorm.DB, m *models.Media) error {
+	if err := r.call("Create"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, cur := range r.media {
+		if cur.Hash == m.Hash {
+			cur.UploadedAt = time.Now()
+			r.media[id] = cur
+			*m = cloneMedia(cur)
+			return nil
+		}
+	}
+	if m.Status == "" {
+		m.Status = models.MediaPending
+	}
+	m.UploadedAt = time.Now()
+	id := r.insert(cloneMedia(*m))
+	*m = cloneMedia(r.media[id])
+	return nil
+}
+
+func (r *Media) GetByID(ctx context.Context, id uint) (*models.Media, error) {
+	if err := r.call("GetByID"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.media[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := cloneMedia(m)
+	return &c, nil
+}
+
+func (r *Media) GetByIDs(ctx context.Context, ids []uint) ([]models.Media, error) {
+	if err := r.call("GetByIDs"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Media{}
+	for _, id := range ids {
+		if m, ok := r.media[id]; ok {
+			out = append(out, cloneMedia(m))
+		}
+	}
+	return out, nil
+}
+
+func (r *Media) GetByHash(ctx context.Context, tx *gorm.DB, hash string) (*models.Media, error) {
+	if err := r.call("GetByHash"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.media {
+		if m.Hash == hash {
+			c := cloneMedia(m)
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *Media) GetRendition(ctx context.Context, mediaID uint, name, format string) (*models.MediaRendition, error) {
+	if err := r.call("GetRendition"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rd := range r.media[mediaID].Renditions {
+		if rd.Name == name && rd.Format == format {
+			return &rd, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *Media) ListByPost(ctx context.Context, postID uint) ([]models.Media, error) {
+	if err := r.call("ListByPost"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Media{}
+	for id := range r.links[postID] {
+		out = append(out, cloneMedia(r.media[id]))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *Media) Link(ctx context.Context, tx *gorm.DB, postID, mediaID uint) error {
+	if err := r.call("Link"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.links[postID] == nil {
+		r.links[postID] = make(map[uint]bool)
+	}
+	r.links[postID][mediaID] = true
+	return nil
+}
+
+func (r *Media) Unlink(ctx context.Context, postID, mediaID uint) error {
+	if err := r.call("Unlink"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.links[postID], mediaID)
+	return nil
+}
+
+func (r *Media) UnlinkPost(ctx context.Context, tx *gorm.DB, postID uint) ([]uint, error) {
+	if err := r.call("UnlinkPost"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []uint
+	for id := range r.links[postID] {
+		ids = append(ids, id)
+	}
+	delete(r.links, postID)
+	return ids, nil
+}
+
+// Linked reports whether mediaID is attached to postID.
+func (r *Media) Linked(postID, mediaID uint) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.links[postID][mediaID]
+}
+
+func (r *Media) DeleteOrphan(ctx context.Context, tx *gorm.DB, id uint, minAge time.Duration) (bool, error) {
+	if err := r.call("DeleteOrphan"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.media[id]
+	if !ok || r.linked(id) || !m.UploadedAt.Before(time.Now().Add(-minAge)) {
+		return false, nil
+	}
+	delete(r.media, id)
+	return true, nil
+}
+
+func (r *Media) Unattached(ctx context.Context, minAge time.Duration, limit int) ([]models.Media, error) {
+	if err := r.call("Unattached"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Media{}
+	for id, m := range r.media {
+		if !r.linked(id) && m.UploadedAt.Before(time.Now().Add(-minAge)) {
+			out = append(out, cloneMedia(m))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UploadedAt.Before(out[j].UploadedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// SetUploadedAt backdates the last upload of media id.
+func (r *Media) SetUploadedAt(id uint, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.media[id]
+	m.UploadedAt = t
+	r.media[id] = m
+}
+
+func (r *Media) linked(mediaID uint) bool {
+	for _, ids := range r.links {
+		if ids[mediaID] {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneMedia(m models.Media) models.Media {
+	m.Renditions = append(m.Renditions[:0:0], m.Renditions...)
+	return m
+}
@@ -0,0 +1,426 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+)
+
+// Posts implements the post repository, and the sitemap and activity
+// repositories, which read the same tables. SearchByTag matches published
+// posts whose tags contain the tag, like the tags @> ARRAY[tag] query.
+type Posts struct {
+	calls
+
+	mu       sync.Mutex
+	lastID   uint
+	posts    map[uint]*models.Post
+	history  map[string]uint
+	activity []models.ActivityLog
+}
+
+func NewPosts() *Posts {
+	return &Posts{posts: make(map[uint]*models.Post), history: make(map[string]uint)}
+}
+
+func (r *Posts) Create(ctx context.Context, tx *gorm.DB, p *models.Post) error {
+	if err := r.call("Create"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slugUsed(p.Slug, 0) {
+		return repository.ErrSlugConflict
+	}
+	r.lastID++
+	p.ID = r.lastID
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	r.posts[p.ID] = clonePost(p)
+	return nil
+}
+
+func (r *Posts) Update(ctx context.Context, tx *gorm.DB, p *models.Post) error {
+	if err := r.call("Update"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.posts[p.ID]
+	if !ok {
+		return nil
+	}
+	if r.slugUsed(p.Slug, p.ID) {
+		return repository.ErrSlugConflict
+	}
+	next := clonePost(p)
+	next.CreatedAt = cur.CreatedAt
+	next.UpdatedAt = time.Now()
+	r.posts[p.ID] = next
+	return nil
+}
+
+// slugUsed mirrors the unique index on posts.slug; call with r.mu held.
+func (r *Posts) slugUsed(slug string, except uint) bool {
+	for id, p := range r.posts {
+		if slug != "" && p.Slug == slug && id != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Posts) GetByID(ctx context.Context, id uint) (*models.Post, error) {
+	if err := r.call("GetByID"); err != nil {
+		return nil, err
+	}
+	return r.get(id)
+}
+
+func (r *Posts) GetForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Post, error) {
+	if err := r.call("GetForUpdate"); err != nil {
+		return nil, err
+	}
+	return r.get(id)
+}
+
+func (r *Posts) get(id uint) (*models.Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.posts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return clonePost(p), nil
+}
+
+func (r *Posts) SearchByTag(ctx context.Context, tag string) ([]models.PostSummary, error) {
+	if err := r.call("SearchByTag"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.PostSummary{}
+	for _, p := range r.posts {
+		if p.Status == models.PostPublished && contains(p.Tags, tag) {
+			out = append(out, summary(p))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (r *Posts) ListAfter(ctx context.Context, afterID uint, limit int) ([]models.Post, error) {
+	if err := r.call("ListAfter"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Post{}
+	for id, p := range r.posts {
+		if id > afterID {
+			out = append(out, *clonePost(p))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *Posts) SlugTaken(ctx context.Context, tx *gorm.DB, slug string, postID uint) (bool, error) {
+	if err := r.call("SlugTaken"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, p := range r.posts {
+		if p.Slug == slug && id != postID {
+			return true, nil
+		}
+	}
+	owner, ok := r.history[slug]
+	return ok && owner != postID, nil
+}
+
+func (r *Posts) Slugs(ctx context.Context, tx *gorm.DB, postID uint) ([]string, error) {
+	if err := r.call("Slugs"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var slugs, old []string
+	if p, ok := r.posts[postID]; ok && p.Slug != "" {
+		slugs = append(slugs, p.Slug)
+	}
+	for s, id := range r.history {
+		if id == postID {
+			old = append(old, s)
+		}
+	}
+	sort.Strings(old)
+	return append(slugs, old...), nil
+}
+
+func (r *Posts) Delete(ctx context.Context, tx *gorm.DB, id uint) error {
+	if err := r.call("Delete"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.posts, id)
+	for s, owner := range r.history {
+		if owner == id {
+			delete(r.history, s)
+		}
+	}
+	return nil
+}
+
+func (r *Posts) GetIDBySlug(ctx context.Context, slug string) (uint, error) {
+	if err := r.call("GetIDBySlug"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, p := range r.posts {
+		if p.Slug == slug {
+			return id, nil
+		}
+	}
+	if id, ok := r.history[slug]; ok {
+		return id, nil
+	}
+	return 0, gorm.ErrRecordNotFound
+}
+
+func (r *Posts) MoveSlug(ctx context.Context, tx *gorm.DB, postID uint, oldSlug, newSlug string) error {
+	if err := r.call("MoveSlug"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.history[newSlug] == postID {
+		delete(r.history, newSlug)
+	}
+	if _, ok := r.history[oldSlug]; oldSlug != "" && !ok {
+		r.history[oldSlug] = postID
+	}
+	return nil
+}
+
+func (r *Posts) LogActivity(ctx context.Context, tx *gorm.DB, entry *models.ActivityLog) error {
+	if err := r.call("LogActivity"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.ID = uint(len(r.activity) + 1)
+	entry.LoggedAt = time.Now()
+	r.activity = append(r.activity, *entry)
+	return nil
+}
+
+// Activity returns the logged actions, oldest first.
+func (r *Posts) Activity() []models.ActivityLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.ActivityLog(nil), r.activity...)
+}
+
+func (r *Posts) LatestPublished(ctx context.Context, tag, author string, limit int) ([]models.Post, error) {
+	if err := r.call("LatestPublished"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Post{}
+	for _, p := range r.posts {
+		if p.Status == models.PostPublished && (tag == "" || contains(p.Tags, tag)) && (author == "" || p.Author == author) {
+			out = append(out, *clonePost(p))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ti, tj := publishedOrCreated(&out[i]), publishedOrCreated(&out[j])
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return out[i].ID > out[j].ID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func publishedOrCreated(p *models.Post) time.Time {
+	if p.PublishedAt != nil {
+		return *p.PublishedAt
+	}
+	return p.CreatedAt
+}
+
+// List filters the activity log like repository.ActivityRepository.List.
+func (r *Posts) List(ctx context.Context, f repository.ActivityFilter, limit int) ([]models.ActivityLog, error) {
+	if err := r.call("List"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.ActivityLog{}
+	for i := len(r.activity) - 1; i >= 0 && len(out) < limit; i-- {
+		e := r.activity[i]
+		switch {
+		case f.PostID != 0 && e.PostID != f.PostID,
+			f.ActorID != "" && e.ActorID != f.ActorID,
+			f.Action != "" && e.Action != f.Action,
+			!f.From.IsZero() && e.LoggedAt.Before(f.From),
+			!f.To.IsZero() && !e.LoggedAt.Before(f.To),
+			f.BeforeID != 0 && e.ID >= f.BeforeID:
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (r *Posts) LatestActivityID(ctx context.Context) (uint, error) {
+	if err := r.call("LatestActivityID"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return uint(len(r.activity)), nil
+}
+
+func (r *Posts) Chunks(ctx context.Context, kind string, size int) ([]repository.Chunk, error) {
+	if err := r.call("Chunks"); err != nil {
+		return nil, err
+	}
+	entries, err := r.sitemapEntries(kind)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []repository.Chunk
+	for i, e := range entries {
+		if i%size == 0 {
+			chunks = append(chunks, repository.Chunk{Index: i / size})
+		}
+		if c := &chunks[len(chunks)-1]; e.LastMod.After(c.LastMod) {
+			c.LastMod = e.LastMod
+		}
+	}
+	return chunks, nil
+}
+
+func (r *Posts) Each(ctx context.Context, kind string, chunk, size int, fn func(repository.SitemapEntry) error) error {
+	if err := r.call("Each"); err != nil {
+		return err
+	}
+	entries, err := r.sitemapEntries(kind)
+	if err != nil {
+		return err
+	}
+	for i := chunk * size; i < len(entries) && i < (chunk+1)*size; i++ {
+		if err := fn(entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sitemapEntries lists kind in the order of the repository's SQL: posts by
+// ID, tags and authors by name with their newest published post's lastmod.
+func (r *Posts) sitemapEntries(kind string) ([]repository.SitemapEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]uint, 0, len(r.posts))
+	for id, p := range r.posts {
+		if p.Status == models.PostPublished {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var entries []repository.SitemapEntry
+	latest := map[string]time.Time{}
+	for _, id := range ids {
+		p := r.posts[id]
+		var keys []string
+		switch kind {
+		case "posts":
+			if p.Slug != "" {
+				entries = append(entries, repository.SitemapEntry{Key: p.Slug, LastMod: p.UpdatedAt})
+			}
+		case "tags":
+			keys = p.Tags
+		case "authors":
+			if p.Author != "" {
+				keys = []string{p.Author}
+			}
+		default:
+			return nil, fmt.Errorf("unknown sitemap kind %q", kind)
+		}
+		mod := p.UpdatedAt
+		if p.PublishedAt != nil {
+			mod = *p.PublishedAt
+		}
+		for _, k := range keys {
+			if mod.After(latest[k]) {
+				latest[k] = mod
+			}
+		}
+	}
+	if kind == "posts" {
+		return entries, nil
+	}
+	for k, t := range latest {
+		entries = append(entries, repository.SitemapEntry{Key: k, LastMod: t})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func clonePost(p *models.Post) *models.Post {
+	c := *p
+	c.Tags = append(c.Tags[:0:0], p.Tags...)
+	c.TOC = append(c.TOC[:0:0], p.TOC...)
+	if p.PublishedAt != nil {
+		t := *p.PublishedAt
+		c.PublishedAt = &t
+	}
+	if p.CoverMediaID != nil {
+		id := *p.CoverMediaID
+		c.CoverMediaID = &id
+	}
+	c.CoverMedia = nil
+	return &c
+}
+
+func summary(p *models.Post) models.PostSummary {
+	c := clonePost(p)
+	return models.PostSummary{
+		ID:                 c.ID,
+		Title:              c.Title,
+		Slug:               c.Slug,
+		Tags:               c.Tags,
+		Author:             c.Author,
+		Status:             c.Status,
+		PublishedAt:        c.PublishedAt,
+		CoverMediaID:       c.CoverMediaID,
+		Excerpt:            c.Excerpt,
+		WordCount:          c.WordCount,
+		ReadingTimeMinutes: c.ReadingTimeMinutes,
+		TOC:                c.TOC,
+		CreatedAt:          c.CreatedAt,
+		UpdatedAt:          c.UpdatedAt,
+	}
+}
@@ -0,0 +1,154 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+// Search implements the Elasticsearch post index. Documents go through
+// JSON like they would over the wire, so numbers come back as float64.
+// SearchPosts matches any query term in title or content, case-insensitively,
+// and ranks by the number of terms matched; FindRelatedPosts ranks by shared
+// tags. Both leave out documents that aren't published.
+type Search struct {
+	calls
+
+	mu   sync.Mutex
+	docs map[uint]map[string]interface{}
+}
+
+func NewSearch() *Search {
+	return &Search{docs: make(map[uint]map[string]interface{})}
+}
+
+func (s *Search) IndexPost(ctx context.Context, id uint, doc map[string]interface{}) error {
+	if err := s.call("IndexPost"); err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[id] = stored
+	return nil
+}
+
+func (s *Search) DeletePost(ctx context.Context, id uint) error {
+	if err := s.call("DeletePost"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.docs, id)
+	return nil
+}
+
+// Doc returns the indexed document for id, or nil.
+func (s *Search) Doc(id uint) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.docs[id]; ok {
+		return copyDoc(d, "")
+	}
+	return nil
+}
+
+func (s *Search) SearchPosts(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	if err := s.call("SearchPosts"); err != nil {
+		return nil, err
+	}
+	terms := strings.Fields(strings.ToLower(query))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hits []scored
+	for id, d := range s.docs {
+		if d["status"] != models.PostPublished {
+			continue
+		}
+		text := strings.ToLower(fmt.Sprint(d["title"]) + " " + fmt.Sprint(d["content"]))
+		n := 0
+		for _, t := range terms {
+			if strings.Contains(text, t) {
+				n++
+			}
+		}
+		if n > 0 {
+			hits = append(hits, scored{id: id, score: n, doc: copyDoc(d, "content")})
+		}
+	}
+	return ranked(hits, 0), nil
+}
+
+func (s *Search) FindRelatedPosts(ctx context.Context, postID uint, tags []string, limit int) ([]map[string]interface{}, error) {
+	if err := s.call("FindRelatedPosts"); err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	if len(tags) == 0 {
+		return out, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hits []scored
+	for id, d := range s.docs {
+		if id == postID || d["status"] != models.PostPublished {
+			continue
+		}
+		docTags, _ := d["tags"].([]interface{})
+		n := 0
+		for _, t := range docTags {
+			if contains(tags, fmt.Sprint(t)) {
+				n++
+			}
+		}
+		if n > 0 {
+			hits = append(hits, scored{id: id, score: n, doc: copyDoc(d, "content")})
+		}
+	}
+	return append(out, ranked(hits, limit)...), nil
+}
+
+type scored struct {
+	id    uint
+	score int
+	doc   map[string]interface{}
+}
+
+func ranked(hits []scored, limit int) []map[string]interface{} {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id < hits[j].id
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	var out []map[string]interface{}
+	for _, h := range hits {
+		out = append(out, h.doc)
+	}
+	return out
+}
+
+func copyDoc(d map[string]interface{}, without string) map[string]interface{} {
+	c := make(map[string]interface{}, len(d))
+	for k, v := range d {
+		if k != without {
+			c[k] = v
+		}
+	}
+	return c
+}
@@ -0,0 +1,192 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+// Webhooks implements the webhook repository. Enqueue creates a pending
+// delivery for every active webhook subscribed to the event and also
+// remembers the event itself, subscribed or not.
+type Webhooks struct {
+	calls
+
+	mu         sync.Mutex
+	lastID     uint
+	lastDelID  uint
+	hooks      map[uint]models.Webhook
+	deliveries map[uint]models.WebhookDelivery
+	events     []string
+}
+
+func NewWebhooks() *Webhooks {
+	return &Webhooks{hooks: make(map[uint]models.Webhook), deliveries: make(map[uint]models.WebhookDelivery)}
+}
+
+func (r *Webhooks) Create(ctx context.Context, w *models.Webhook) error {
+	if err := r.call("Create"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	w.ID = r.lastID
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = w.CreatedAt
+	r.hooks[w.ID] = cloneWebhook(*w)
+	return nil
+}
+
+func (r *Webhooks) GetByID(ctx context.Context, id uint) (*models.Webhook, error) {
+	if err := r.call("GetByID"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.hooks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	c := cloneWebhook(w)
+	return &c, nil
+}
+
+func (r *Webhooks) List(ctx context.Context) ([]models.Webhook, error) {
+	if err := r.call("List"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Webhook{}
+	for _, w := range r.hooks {
+		out = append(out, cloneWebhook(w))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *Webhooks) Update(ctx context.Context, w *models.Webhook) error {
+	if err := r.call("Update"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.hooks[w.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	next := cloneWebhook(*w)
+	next.CreatedAt = cur.CreatedAt
+	next.UpdatedAt = time.Now()
+	r.hooks[w.ID] = next
+	return nil
+}
+
+func (r *Webhooks) Delete(ctx context.Context, id uint) error {
+	if err := r.call("Delete"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.hooks[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.hooks, id)
+	for did, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, did)
+		}
+	}
+	return nil
+}
+
+func (r *Webhooks) Enqueue(ctx context.Context, tx *gorm.DB, event string, payload []byte) error {
+	if err := r.call("Enqueue"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	for _, w := range r.hooks {
+		if w.Active && contains(w.Events, event) {
+			r.addDelivery(models.WebhookDelivery{
+				WebhookID: w.ID,
+				Event:     event,
+				Payload:   json.RawMessage(append([]byte(nil), payload...)),
+			})
+		}
+	}
+	return nil
+}
+
+func (r *Webhooks) addDelivery(d models.WebhookDelivery) models.WebhookDelivery {
+	r.lastDelID++
+	d.ID = r.lastDelID
+	d.Status = models.DeliveryPending
+	d.NextAttemptAt = time.Now()
+	d.CreatedAt = d.NextAttemptAt
+	d.UpdatedAt = d.NextAttemptAt
+	r.deliveries[d.ID] = d
+	return d
+}
+
+// Events returns every enqueued event name in order.
+func (r *Webhooks) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func (r *Webhooks) GetDelivery(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error) {
+	if err := r.call("GetDelivery"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok || d.WebhookID != webhookID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &d, nil
+}
+
+func (r *Webhooks) ListDeliveries(ctx context.Context, webhookID uint, status string, limit int) ([]models.WebhookDelivery, error) {
+	if err := r.call("ListDeliveries"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *Webhooks) Redeliver(ctx context.Context, d *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	if err := r.call("Redeliver"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	again := r.addDelivery(models.WebhookDelivery{WebhookID: d.WebhookID, Event: d.Event, Payload: d.Payload})
+	return &again, nil
+}
+
+func cloneWebhook(w models.Webhook) models.Webhook {
+	w.Events = append(w.Events[:0:0], w.Events...)
+	return w
+}
@@ -0,0 +1,356 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+	FormatJSON = "json"
+)
+
+var Formats = []string{FormatRSS, FormatAtom, FormatJSON}
+
+var contentTypes = map[string]string{
+	FormatRSS:  "application/rss+xml; charset=utf-8",
+	FormatAtom: "application/atom+xml; charset=utf-8",
+	FormatJSON: "application/feed+json; charset=utf-8",
+}
+
+// Scope selects which posts a feed contains. At most one of Tag and Author is set.
+type Scope struct {
+	Tag    string
+	Author string
+}
+
+func (s Scope) path() string {
+	switch {
+	case s.Tag != "":
+		return "/tags/" + url.PathEscape(s.Tag)
+	case s.Author != "":
+		return "/authors/" + url.PathEscape(s.Author)
+	default:
+		return ""
+	}
+}
+
+func (s Scope) title(site string) string {
+	switch {
+	case s.Tag != "":
+		return fmt.Sprintf("%s — #%s", site, s.Tag)
+	case s.Author != "":
+		return fmt.Sprintf("%s — %s", site, s.Author)
+	default:
+		return site
+	}
+}
+
+// CacheKey is the Redis key a rendered feed is stored under.
+func CacheKey(format string, s Scope) string {
+	switch {
+	case s.Tag != "":
+		return "feed:" + format + ":tag:" + s.Tag
+	case s.Author != "":
+		return "feed:" + format + ":author:" + s.Author
+	default:
+		return "feed:" + format
+	}
+}
+
+// AffectedKeys lists every cached feed a post with these tags and author can appear in.
+func AffectedKeys(tags []string, author string) []string {
+	scopes := []Scope{{}}
+	for _, t := range tags {
+		scopes = append(scopes, Scope{Tag: t})
+	}
+	if author != "" {
+		scopes = append(scopes, Scope{Author: author})
+	}
+	var keys []string
+	for _, s := range scopes {
+		for _, f := range Formats {
+			keys = append(keys, CacheKey(f, s))
+		}
+	}
+	return keys
+}
+
+type Site struct {
+	Title   string
+	BaseURL string
+}
+
+func (s Site) postURL(p *models.Post) string {
+	return s.BaseURL + "/posts/by-slug/" + url.PathEscape(p.Slug)
+}
+
+// postID is stable across slug changes, as feed readers use it to dedupe entries.
+func (s Site) postID(p *models.Post) string {
+	return fmt.Sprintf("%s/posts/%d", s.BaseURL, p.ID)
+}
+
+// Rendered is a serialized feed plus what clients need for conditional GETs.
+type Rendered struct {
+	Body         []byte    `json:"body"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Render serializes posts (newest first) as format.
+func Render(site Site, format string, scope Scope, posts []models.Post) (*Rendered, error) {
+	selfURL := fmt.Sprintf("%s%s/feed.%s", site.BaseURL, scope.path(), format)
+	updated := time.Unix(0, 0).UTC()
+	for i := range posts {
+		if posts[i].UpdatedAt.After(updated) {
+			updated = posts[i].UpdatedAt.UTC()
+		}
+	}
+
+	var body []byte
+	var err error
+	switch format {
+	case FormatRSS:
+		body, err = renderRSS(site, scope, selfURL, updated, posts)
+	case FormatAtom:
+		body, err = renderAtom(site, scope, selfURL, updated, posts)
+	case FormatJSON:
+		body, err = renderJSON(site, scope, selfURL, posts)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	return &Rendered{
+		Body:         body,
+		ContentType:  contentTypes[format],
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LastModified: updated.Truncate(time.Second),
+	}, nil
+}
+
+func published(p *models.Post) time.Time {
+	if p.PublishedAt != nil {
+		return p.PublishedAt.UTC()
+	}
+	return p.CreatedAt.UTC()
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	Content     cdata    `xml:"content:encoded"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+func renderRSS(site Site, scope Scope, selfURL string, updated time.Time, posts []models.Post) ([]byte, error) {
+	ch := rssChannel{
+		Title:         scope.title(site.Title),
+		Link:          site.BaseURL + "/",
+		Description:   scope.title(site.Title),
+		LastBuildDate: updated.Format(time.RFC1123Z),
+		Self:          atomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+	}
+	for i := range posts {
+		p := &posts[i]
+		ch.Items = append(ch.Items, rssItem{
+			Title:       p.Title,
+			Link:        site.postURL(p),
+			GUID:        rssGUID{Value: site.postID(p)},
+			PubDate:     published(p).Format(time.RFC1123Z),
+			Creator:     p.Author,
+			Categories:  p.Tags,
+			Description: p.Excerpt,
+			Content:     cdata{Value: p.ContentHTML},
+		})
+	}
+	out, err := xml.MarshalIndent(rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: ch,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderAtom(site Site, scope Scope, selfURL string, updated time.Time, posts []models.Post) ([]byte, error) {
+	f := atomFeed{
+		Title:   scope.title(site.Title),
+		ID:      selfURL,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: site.BaseURL + "/", Rel: "alternate"},
+		},
+	}
+	for i := range posts {
+		p := &posts[i]
+		e := atomEntry{
+			Title:     p.Title,
+			ID:        site.postID(p),
+			Link:      atomLink{Href: site.postURL(p), Rel: "alternate"},
+			Published: published(p).Format(time.RFC3339),
+			Updated:   p.UpdatedAt.UTC().Format(time.RFC3339),
+			Summary:   p.Excerpt,
+			Content:   atomContent{Type: "html", Value: p.ContentHTML},
+		}
+		if p.Author != "" {
+			e.Author = &atomAuthor{Name: p.Author}
+		}
+		for _, t := range p.Tags {
+			e.Categories = append(e.Categories, atomCategory{Term: t})
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	out, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Tags          []string     `json:"tags,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func renderJSON(site Site, scope Scope, selfURL string, posts []models.Post) ([]byte, error) {
+	f := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       scope.title(site.Title),
+		HomePageURL: site.BaseURL + "/",
+		FeedURL:     selfURL,
+		Items:       []jsonItem{},
+	}
+	for i := range posts {
+		p := &posts[i]
+		item := jsonItem{
+			ID:            site.postID(p),
+			URL:           site.postURL(p),
+			Title:         p.Title,
+			ContentHTML:   p.ContentHTML,
+			Summary:       p.Excerpt,
+			DatePublished: published(p).Format(time.RFC3339),
+			DateModified:  p.UpdatedAt.UTC().Format(time.RFC3339),
+			Tags:          p.Tags,
+		}
+		if p.Author != "" {
+			item.Authors = []jsonAuthor{{Name: p.Author}}
+		}
+		f.Items = append(f.Items, item)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
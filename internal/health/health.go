@@ -0,0 +1,103 @@
+// Package health runs the readiness checks behind /readyz and tracks
+// whether the process is draining before shutdown.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+)
+
+// Check is one dependency probe. Run should return once ctx is done; if it
+// doesn't, the check fails at the timeout anyway.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is what /readyz returns: the overall status and, unless the
+// process is draining, every check's result by name.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+func (r Report) OK() bool { return r.Status == StatusOK }
+
+// Checker runs its checks concurrently, each under its own timeout.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Drain makes every later Ready report draining, so load balancers stop
+// sending traffic before the server shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs the checks and reports ok only if all of them passed.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDraining}
+	}
+	rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk Check) {
+			defer wg.Done()
+			res := c.run(ctx, chk)
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[chk.Name] = res
+			if res.Status != StatusOK {
+				rep.Status = StatusFailing
+			}
+		}(chk)
+	}
+	wg.Wait()
+	return rep
+}
+
+func (c *Checker) run(ctx context.Context, chk Check) Result {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- chk.Run(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := Result{Status: StatusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status, res.Error = StatusFailing, err.Error()
+	}
+	return res
+}
@@ -0,0 +1,160 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/buckket/go-blurhash"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatGIF  = "gif"
+
+	// refuse decompression bombs before allocating the pixel buffer
+	maxPixels   = 50_000_000
+	jpegQuality = 85
+)
+
+var (
+	ErrTooManyPixels = errors.New("image dimensions too large")
+
+	Extensions = map[string]string{FormatJPEG: ".jpg", FormatPNG: ".png", FormatWebP: ".webp", FormatGIF: ".gif"}
+	MimeTypes  = map[string]string{FormatJPEG: "image/jpeg", FormatPNG: "image/png", FormatWebP: "image/webp", FormatGIF: "image/gif"}
+)
+
+// Spec describes a rendition. Zero Height means "scale to Width keeping the
+// aspect ratio"; Crop fills the exact box from the centre.
+type Spec struct {
+	Name   string
+	Width  int
+	Height int
+	Crop   bool
+}
+
+var Renditions = []Spec{
+	{Name: "thumbnail", Width: 320, Height: 320, Crop: true},
+	{Name: "medium", Width: 800},
+	{Name: "large", Width: 1600},
+}
+
+type Output struct {
+	Name   string
+	Format string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+type Result struct {
+	Width    int
+	Height   int
+	BlurHash string
+	// Original is the upload re-encoded without metadata (EXIF, XMP, ...).
+	// It is nil for GIFs, which carry no EXIF and would lose their animation.
+	Original   *Output
+	Renditions []Output
+}
+
+// Process decodes data, applies the EXIF orientation and produces every
+// rendition in its natural format (JPEG, or PNG when it has transparency)
+// plus a WebP copy. Re-encoding drops all metadata, which strips EXIF.
+func Process(data []byte) (*Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	b := img.Bounds()
+	res := &Result{Width: b.Dx(), Height: b.Dy()}
+
+	natural := FormatJPEG
+	if !opaque(img) {
+		natural = FormatPNG
+	}
+	if format != FormatGIF {
+		origFormat := natural
+		if format == "png" || format == "webp" {
+			origFormat = format
+		}
+		enc, err := encode(img, origFormat)
+		if err != nil {
+			return nil, err
+		}
+		res.Original = &Output{Name: "original", Format: origFormat, Width: b.Dx(), Height: b.Dy(), Data: enc}
+	}
+
+	for _, spec := range Renditions {
+		r := resize(img, spec)
+		for _, f := range []string{natural, FormatWebP} {
+			enc, err := encode(r, f)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", spec.Name, f, err)
+			}
+			rb := r.Bounds()
+			res.Renditions = append(res.Renditions, Output{Name: spec.Name, Format: f, Width: rb.Dx(), Height: rb.Dy(), Data: enc})
+		}
+	}
+
+	res.BlurHash, err = blurhash.Encode(4, 3, imaging.Fit(img, 32, 32, imaging.Box))
+	if err != nil {
+		return nil, fmt.Errorf("blurhash: %w", err)
+	}
+	return res, nil
+}
+
+// resize never upscales: small images keep their size (a thumbnail of a
+// small image is still cropped to the spec's aspect ratio).
+func resize(img image.Image, spec Spec) image.Image {
+	b := img.Bounds()
+	if spec.Crop {
+		w, h := spec.Width, spec.Height
+		if b.Dx() < w || b.Dy() < h {
+			scale := min(float64(b.Dx())/float64(w), float64(b.Dy())/float64(h))
+			w, h = max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+		}
+		return imaging.Fill(img, w, h, imaging.Center, imaging.Lanczos)
+	}
+	if b.Dx() <= spec.Width {
+		return img
+	}
+	return imaging.Resize(img, spec.Width, 0, imaging.Lanczos)
+}
+
+func encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatJPEG:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case FormatPNG:
+		err = png.Encode(&buf, img)
+	case FormatWebP:
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		err = fmt.Errorf("unsupported output format %q", format)
+	}
+	return buf.Bytes(), err
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package markup
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	xhtml "golang.org/x/net/html"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatPlain    = "plain"
+)
+
+var (
+	md = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+	policy = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// keep goldmark's "language-xxx" classes so highlighters can pick them up
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	return p
+}
+
+// Render converts src written in format into sanitized HTML.
+func Render(format, src string) (string, error) {
+	switch format {
+	case FormatMarkdown:
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(src), &buf); err != nil {
+			return "", err
+		}
+		return policy.Sanitize(buf.String()), nil
+	case FormatHTML:
+		return policy.Sanitize(src), nil
+	case FormatPlain:
+		return plainToHTML(src), nil
+	default:
+		return "", fmt.Errorf("unsupported content format %q", format)
+	}
+}
+
+func plainToHTML(src string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "blockquote": true, "table": true, "tr": true, "td": true, "th": true, "hr": true,
+}
+
+// PlainText strips markup from rendered HTML, keeping only the readable text.
+func PlainText(renderedHTML string) string {
+	z := xhtml.NewTokenizer(strings.NewReader(renderedHTML))
+	var b strings.Builder
+	skip := 0
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			return collapse(b.String())
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "script" || tag == "style" {
+				skip++
+			}
+			if blockElements[tag] {
+				b.WriteByte(' ')
+			}
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if (tag == "script" || tag == "style") && skip > 0 {
+				skip--
+			}
+			if blockElements[tag] {
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
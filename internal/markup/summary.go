@@ -0,0 +1,143 @@
+package markup
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	xhtml "golang.org/x/net/html"
+)
+
+const wordsPerMinute = 200
+
+type Heading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+type Summary struct {
+	Excerpt            string
+	WordCount          int
+	ReadingTimeMinutes int
+	Headings           []Heading
+}
+
+// Summarize derives listing metadata from rendered HTML. The excerpt is the
+// first paragraph, cut back to a sentence (or word) boundary within maxLen runes.
+func Summarize(renderedHTML string, maxLen int) Summary {
+	z := xhtml.NewTokenizer(strings.NewReader(renderedHTML))
+	var (
+		all, para, head strings.Builder
+		headings        []Heading
+		cur             *Heading
+		inPara, skip    int
+		firstPara       string
+	)
+	for tt := z.Next(); tt != xhtml.ErrorToken; tt = z.Next() {
+		switch tt {
+		case xhtml.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := z.Text()
+			all.Write(text)
+			if inPara > 0 && firstPara == "" {
+				para.Write(text)
+			}
+			if cur != nil {
+				head.Write(text)
+			}
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			switch {
+			case tag == "script" || tag == "style":
+				skip++
+			case tag == "p":
+				inPara++
+			case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+				cur = &Heading{Level: int(tag[1] - '0')}
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					if string(k) == "id" {
+						cur.ID = string(v)
+					}
+				}
+				head.Reset()
+			}
+			if blockElements[tag] {
+				all.WriteByte(' ')
+			}
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case (tag == "script" || tag == "style") && skip > 0:
+				skip--
+			case tag == "p" && inPara > 0:
+				inPara--
+				if inPara == 0 && firstPara == "" {
+					firstPara = collapse(para.String())
+					para.Reset()
+				}
+			case cur != nil && len(tag) == 2 && tag[0] == 'h' && int(tag[1]-'0') == cur.Level:
+				cur.Text = collapse(head.String())
+				if cur.Text != "" {
+					headings = append(headings, *cur)
+				}
+				cur = nil
+			}
+			if blockElements[tag] {
+				all.WriteByte(' ')
+			}
+		}
+	}
+
+	text := collapse(all.String())
+	words := len(strings.Fields(text))
+	if firstPara == "" {
+		firstPara = text
+	}
+	minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+	return Summary{
+		Excerpt:            Excerpt(firstPara, maxLen),
+		WordCount:          words,
+		ReadingTimeMinutes: minutes,
+		Headings:           headings,
+	}
+}
+
+// Excerpt shortens text to at most maxLen runes, preferring to end on a
+// sentence boundary and falling back to a word boundary plus an ellipsis.
+func Excerpt(text string, maxLen int) string {
+	if utf8.RuneCountInString(text) <= maxLen {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:maxLen])
+	if i := lastSentenceEnd(cut); i > len(cut)/2 {
+		return cut[:i]
+	}
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-") + "…"
+}
+
+func lastSentenceEnd(s string) int {
+	best := -1
+	for _, sep := range []string{". ", "! ", "? "} {
+		if i := strings.LastIndex(s, sep); i >= 0 && i+1 > best {
+			best = i + 1
+		}
+	}
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "!") || strings.HasSuffix(s, "?") {
+		best = len(s)
+	}
+	return best
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
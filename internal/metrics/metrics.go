@@ -0,0 +1,199 @@
+// Package metrics defines the Prometheus metrics the service exports on
+// /metrics. Labels are kept to small fixed sets (route templates, status
+// codes, operation names) so series counts stay bounded.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/example/blog-service/internal/cache"
+)
+
+const namespace = "blog"
+
+// Metrics owns a registry and the collectors written to by the HTTP layer,
+// the search client and the services. Each instance has its own registry,
+// so tests can assert on a fresh one. A nil *Metrics records nothing.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight prometheus.Gauge
+
+	esRequests *prometheus.CounterVec
+	esDuration *prometheus.HistogramVec
+
+	postWrites    *prometheus.CounterVec
+	searches      *prometheus.CounterVec
+	searchesEmpty *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_total",
+			Help: "HTTP requests served, by route template and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "Time to serve HTTP requests, by route template.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		esRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "elasticsearch", Name: "requests_total",
+			Help: "Elasticsearch requests by operation and result (ok, error).",
+		}, []string{"operation", "result"}),
+		esDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "elasticsearch", Name: "request_duration_seconds",
+			Help:    "Elasticsearch request latency by operation.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation"}),
+		postWrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "posts", Name: "writes_total",
+			Help: "Posts created, updated and deleted.",
+		}, []string{"action"}),
+		searches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "search", Name: "queries_total",
+			Help: "Searches by kind (tag, fulltext).",
+		}, []string{"kind"}),
+		searchesEmpty: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "search", Name: "zero_results_total",
+			Help: "Searches that found nothing, by kind.",
+		}, []string{"kind"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.httpInFlight,
+		m.esRequests, m.esDuration,
+		m.postWrites, m.searches, m.searchesEmpty,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// RegisterDB exports the connection pool stats of db, labelled
+// db_name=name ("blog" for the primary).
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCache exports the Fetch counters of c, read at scrape time.
+func (m *Metrics) RegisterCache(c cache.Cache) {
+	m.Registry.MustRegister(cacheCollector{c})
+}
+
+// RequestStarted is called when the HTTP layer starts serving a request;
+// call the returned function with the route template and status when done.
+func (m *Metrics) RequestStarted(method string) func(route string, status int) {
+	if m == nil {
+		return func(string, int) {}
+	}
+	start := time.Now()
+	m.httpInFlight.Inc()
+	return func(route string, status int) {
+		m.httpInFlight.Dec()
+		m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		m.httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ElasticRequest records one Elasticsearch round trip.
+func (m *Metrics) ElasticRequest(operation string, d time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if failed {
+		result = "error"
+	}
+	m.esRequests.WithLabelValues(operation, result).Inc()
+	m.esDuration.WithLabelValues(operation).Observe(d.Seconds())
+}
+
+func (m *Metrics) PostCreated() { m.postWrite("create") }
+func (m *Metrics) PostUpdated() { m.postWrite("update") }
+func (m *Metrics) PostDeleted() { m.postWrite("delete") }
+
+func (m *Metrics) postWrite(action string) {
+	if m != nil {
+		m.postWrites.WithLabelValues(action).Inc()
+	}
+}
+
+// Searched records a search of kind ("tag" or "fulltext") that returned
+// results hits.
+func (m *Metrics) Searched(kind string, results int) {
+	if m == nil {
+		return
+	}
+	m.searches.WithLabelValues(kind).Inc()
+	if results == 0 {
+		m.searchesEmpty.WithLabelValues(kind).Inc()
+	}
+}
+
+var (
+	cacheRequestsDesc = prometheus.NewDesc(namespace+"_cache_fetches_total",
+		"Cache-aside reads by result (hit, negative_hit, miss, stale).", []string{"result"}, nil)
+	cacheRefreshesDesc = prometheus.NewDesc(namespace+"_cache_refreshes_total",
+		"Background refreshes of stale entries.", nil, nil)
+	cacheLockWaitsDesc = prometheus.NewDesc(namespace+"_cache_lock_waits_total",
+		"Reads that waited for another process to load the value.", nil, nil)
+	cacheErrorsDesc = prometheus.NewDesc(namespace+"_cache_errors_total",
+		"Cache backend errors during reads.", nil, nil)
+	cacheLocalDesc = prometheus.NewDesc(namespace+"_cache_local_lookups_total",
+		"In-process cache lookups by namespace and result (hit, miss).", []string{"namespace", "result"}, nil)
+	cacheLocalEvictionsDesc = prometheus.NewDesc(namespace+"_cache_local_evictions_total",
+		"Entries evicted from the in-process cache to stay under its size limit.", []string{"namespace"}, nil)
+)
+
+// cacheCollector turns cache.Stats into counters.
+type cacheCollector struct {
+	c cache.Cache
+}
+
+func (cc cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheRequestsDesc
+	ch <- cacheRefreshesDesc
+	ch <- cacheLockWaitsDesc
+	ch <- cacheErrorsDesc
+	ch <- cacheLocalDesc
+	ch <- cacheLocalEvictionsDesc
+}
+
+func (cc cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	st := cc.c.Stats()
+	counter := func(desc *prometheus.Desc, v uint64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v), labels...)
+	}
+	counter(cacheRequestsDesc, st.Hits, "hit")
+	counter(cacheRequestsDesc, st.NegHits, "negative_hit")
+	counter(cacheRequestsDesc, st.Misses, "miss")
+	counter(cacheRequestsDesc, st.Stale, "stale")
+	counter(cacheRefreshesDesc, st.Refreshes)
+	counter(cacheLockWaitsDesc, st.LockWaits)
+	counter(cacheErrorsDesc, st.Errors)
+	for ns, l := range st.Local {
+		counter(cacheLocalDesc, l.Hits, ns, "hit")
+		counter(cacheLocalDesc, l.Misses, ns, "miss")
+		counter(cacheLocalEvictionsDesc, l.Evictions, ns)
+	}
+}
@@ -1,10 +1,52 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type ActivityLog struct {
-	ID       uint      `gorm:"primaryKey" json:"id"`
-	Action   string    `gorm:"type:varchar(50);not null" json:"action"`
-	PostID   uint      `gorm:"index;not null" json:"post_id"`
-	LoggedAt time.Time `gorm:"autoCreateTime" json:"logged_at"`
-} 
\ No newline at end of file
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Action    string    `gorm:"type:varchar(50);not null;index" json:"action"`
+	PostID    uint      `gorm:"index;not null" json:"post_id"`
+	ActorID   string    `gorm:"type:varchar(100);index" json:"actor_id,omitempty"`
+	IP        string    `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	UserAgent string    `gorm:"type:text" json:"user_agent,omitempty"`
+	RequestID string    `gorm:"type:varchar(100);index" json:"request_id,omitempty"`
+	Changes   Changes   `gorm:"type:jsonb" json:"changes,omitempty"`
+	LoggedAt  time.Time `gorm:"autoCreateTime;index" json:"logged_at"`
+}
+
+// FieldChange is the value of one field before and after a write. Before is
+// null for created posts, After is null for deleted ones.
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Changes maps field names to how they changed; stored as jsonb.
+type Changes map[string]FieldChange
+
+func (c Changes) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+func (c *Changes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("unsupported Changes source %T", src)
+	}
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Admin is an actor allowed to write when REQUIRE_ADMIN_ACTOR is on. ID is
+// the X-Actor-ID the gateway sends for them.
+type Admin struct {
+	ID        string    `gorm:"type:varchar(100);primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(255)" json:"name,omitempty"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+}
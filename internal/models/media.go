@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+const (
+	MediaPending    = "pending"
+	MediaProcessing = "processing"
+	MediaReady      = "ready"
+	MediaFailed     = "failed"
+)
+
+// Media is an uploaded file. Files are content-addressed: Hash is the SHA-256
+// of the uploaded bytes, so identical uploads share one row and one stored object.
+type Media struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Hash         string    `gorm:"type:char(64);uniqueIndex;not null" json:"hash"`
+	StorageKey   string    `gorm:"type:varchar(255);not null" json:"-"`
+	MimeType     string    `gorm:"type:varchar(100);not null" json:"mime_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	OriginalName string    `gorm:"type:varchar(255)" json:"original_name"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	// bumped by every upload of the same bytes; see MediaService.CollectGarbage
+	UploadedAt time.Time `gorm:"not null;default:now();index" json:"-"`
+
+	// filled in by the background processor
+	Status     string           `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`
+	Attempts   int              `gorm:"not null;default:0" json:"-"`
+	ClaimedAt  *time.Time       `json:"-"`
+	Error      string           `gorm:"type:text" json:"error,omitempty"`
+	Width      int              `json:"width,omitempty"`
+	Height     int              `json:"height,omitempty"`
+	BlurHash   string           `gorm:"type:varchar(64)" json:"blurhash,omitempty"`
+	Renditions []MediaRendition `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE" json:"renditions"`
+
+	URL string `gorm:"-" json:"url"`
+}
+
+func (Media) TableName() string { return "media" }
+
+// MediaRendition is a resized and/or re-encoded copy of a Media.
+type MediaRendition struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	MediaID    uint      `gorm:"not null;uniqueIndex:idx_media_rendition" json:"-"`
+	Name       string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_media_rendition" json:"name"`
+	Format     string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_media_rendition" json:"format"`
+	StorageKey string    `gorm:"type:varchar(255);not null" json:"-"`
+	MimeType   string    `gorm:"type:varchar(100);not null" json:"mime_type"`
+	Hash       string    `gorm:"type:char(64);not null" json:"-"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"-"`
+
+	URL string `gorm:"-" json:"url"`
+}
+
+// PostMedia links media to the posts that use it.
+type PostMedia struct {
+	PostID    uint      `gorm:"primaryKey" json:"post_id"`
+	MediaID   uint      `gorm:"primaryKey;index" json:"media_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// the foreign keys, created by migration 0001
+	Post  *Post  `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE" json:"-"`
+	Media *Media `gorm:"foreignKey:MediaID;constraint:OnDelete:RESTRICT" json:"-"`
+}
+
+func (PostMedia) TableName() string { return "post_media" }
+
+// CoverImage is what listings need to show a post's cover.
+type CoverImage struct {
+	MediaID      uint   `json:"media_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	BlurHash     string `json:"blurhash"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
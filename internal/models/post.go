@@ -6,11 +6,52 @@ import (
 	"github.com/lib/pq"
 )
 
+const (
+	PostDraft     = "draft"
+	PostPublished = "published"
+)
+
 type Post struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Title     string         `gorm:"type:varchar(255);not null" json:"title"`
-	Content   string         `gorm:"type:text;not null" json:"content"`
-	Tags      pq.StringArray `gorm:"type:text[]" json:"tags"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-} 
\ No newline at end of file
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Title         string         `gorm:"type:varchar(255);not null" json:"title"`
+	Slug          string         `gorm:"type:varchar(255);uniqueIndex" json:"slug"`
+	Content       string         `gorm:"type:text;not null" json:"content"`
+	ContentFormat string         `gorm:"type:varchar(20);not null;default:plain" json:"content_format"`
+	ContentHTML   string         `gorm:"type:text" json:"content_html"`
+	Tags          pq.StringArray `gorm:"type:text[]" json:"tags"`
+	CoverMediaID  *uint          `gorm:"index" json:"cover_media_id"`
+	Author        string         `gorm:"type:varchar(100);index" json:"author"`
+	Status        string         `gorm:"type:varchar(20);not null;default:published;index" json:"status"`
+	PublishedAt   *time.Time     `gorm:"index" json:"published_at"`
+
+	// derived from ContentHTML on every write
+	Excerpt            string `gorm:"type:text" json:"excerpt"`
+	WordCount          int    `gorm:"not null;default:0" json:"word_count"`
+	ReadingTimeMinutes int    `gorm:"not null;default:0" json:"reading_time_minutes"`
+	TOC                TOC    `gorm:"type:jsonb" json:"toc"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	CoverMedia *Media `gorm:"foreignKey:CoverMediaID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+// PostSummary is the listing projection of Post; it leaves out the content
+// columns so list and search responses stay small.
+type PostSummary struct {
+	ID                 uint           `json:"id"`
+	Title              string         `json:"title"`
+	Slug               string         `json:"slug"`
+	Tags               pq.StringArray `gorm:"type:text[]" json:"tags"`
+	Author             string         `json:"author"`
+	Status             string         `json:"status"`
+	PublishedAt        *time.Time     `json:"published_at"`
+	CoverMediaID       *uint          `json:"cover_media_id"`
+	Cover              *CoverImage    `gorm:"-" json:"cover,omitempty"`
+	Excerpt            string         `json:"excerpt"`
+	WordCount          int            `json:"word_count"`
+	ReadingTimeMinutes int            `json:"reading_time_minutes"`
+	TOC                TOC            `json:"toc"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
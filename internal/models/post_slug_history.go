@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PostSlugHistory keeps slugs a post used to have so old links keep resolving.
+type PostSlugHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	PostID    uint      `gorm:"index;not null" json:"post_id"`
+	Slug      string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"slug"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (PostSlugHistory) TableName() string { return "post_slug_history" }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Post lifecycle events a webhook can subscribe to.
+const (
+	EventPostCreated   = "post.created"
+	EventPostUpdated   = "post.updated"
+	EventPostPublished = "post.published"
+	EventPostDeleted   = "post.deleted"
+)
+
+const (
+	DeliveryPending    = "pending"
+	DeliveryInProgress = "in_progress"
+	DeliverySucceeded  = "succeeded"
+	DeliveryFailed     = "failed"
+)
+
+// Webhook is a subscription of an external URL to post events. Payloads are
+// signed with Secret, which is never returned by the API.
+type Webhook struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	URL       string         `gorm:"type:text;not null" json:"url"`
+	Events    pq.StringArray `gorm:"type:text[];not null" json:"events"`
+	Secret    string         `gorm:"type:varchar(255);not null" json:"-"`
+	Active    bool           `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WebhookDelivery is one queued event for one webhook. The row doubles as the
+// delivery log: it keeps the outcome of the latest attempt.
+type WebhookDelivery struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	WebhookID     uint            `gorm:"not null;index" json:"webhook_id"`
+	Webhook       *Webhook        `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Event         string          `gorm:"type:varchar(50);not null" json:"event"`
+	Payload       json.RawMessage `gorm:"type:jsonb;not null" json:"payload"`
+	Status        string          `gorm:"type:varchar(20);not null;default:pending;index:idx_webhook_delivery_due,priority:1" json:"status"`
+	Attempts      int             `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time       `gorm:"not null;index:idx_webhook_delivery_due,priority:2" json:"next_attempt_at"`
+	ClaimedAt     *time.Time      `json:"-"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	ResponseBody  string          `gorm:"type:text" json:"response_body,omitempty"`
+	Error         string          `gorm:"type:text" json:"error,omitempty"`
+	DurationMs    int64           `json:"duration_ms,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+// ActivityRepository lists the activity log through read, which may pick a
+// replica.
+type ActivityRepository struct{ read Reader }
+
+func NewActivityRepository(read Reader) *ActivityRepository { return &ActivityRepository{read: read} }
+
+// ActivityFilter narrows a listing; zero fields are ignored. BeforeID is the
+// pagination cursor.
+type ActivityFilter struct {
+	PostID   uint
+	ActorID  string
+	Action   string
+	From     time.Time
+	To       time.Time
+	BeforeID uint
+}
+
+// List returns up to limit entries matching f, newest first.
+func (r *ActivityRepository) List(ctx context.Context, f ActivityFilter, limit int) ([]models.ActivityLog, error) {
+	q := r.read(ctx).WithContext(ctx).Model(&models.ActivityLog{})
+	if f.PostID != 0 {
+		q = q.Where("post_id = ?", f.PostID)
+	}
+	if f.ActorID != "" {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("logged_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("logged_at < ?", f.To)
+	}
+	if f.BeforeID != 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	out := []models.ActivityLog{}
+	err := q.Order("id DESC").Limit(limit).Find(&out).Error
+	return out, err
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+var ErrAdminExists = errors.New("admin already exists")
+
+type AdminRepository struct{ db *gorm.DB }
+
+func NewAdminRepository(db *gorm.DB) *AdminRepository { return &AdminRepository{db: db} }
+
+// Create adds a, or returns ErrAdminExists if its ID is taken.
+func (r *AdminRepository) Create(ctx context.Context, a *models.Admin) error {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAdminExists
+	}
+	return nil
+}
+
+func (r *AdminRepository) Exists(ctx context.Context, id string) (bool, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&models.Admin{}).Where("id = ?", id).Count(&n).Error
+	return n > 0, err
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+type MediaRepository struct{ db *gorm.DB }
+
+func NewMediaRepository(db *gorm.DB) *MediaRepository { return &MediaRepository{db: db} }
+
+// LockHash takes a transaction-scoped advisory lock on a content hash.
+// Uploads and garbage collection of the same bytes hold it, so GC can't
+// delete a row or its objects while an upload is reusing them.
+func (r *MediaRepository) LockHash(ctx context.Context, tx *gorm.DB, hash string) error {
+	return tx.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "media:"+hash).Error
+}
+
+// Create inserts m inside tx unless a row with the same hash already exists,
+// in which case that row's uploaded_at is bumped. Either way m ends up
+// holding the stored row.
+func (r *MediaRepository) Create(ctx context.Context, tx *gorm.DB, m *models.Media) error {
+	err := tx.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"uploaded_at": gorm.Expr("now()")}),
+	}).Create(m).Error
+	if err != nil {
+		return err
+	}
+	var stored models.Media
+	if err := tx.WithContext(ctx).Where("hash = ?", m.Hash).Take(&stored).Error; err != nil {
+		return err
+	}
+	*m = stored
+	return nil
+}
+
+func (r *MediaRepository) GetByID(ctx context.Context, id uint) (*models.Media, error) {
+	var m models.Media
+	if err := r.db.WithContext(ctx).Preload("Renditions").First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *MediaRepository) GetByIDs(ctx context.Context, ids []uint) ([]models.Media, error) {
+	var media []models.Media
+	if len(ids) == 0 {
+		return media, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Renditions").Where("id IN ?", ids).Find(&media).Error
+	return media, err
+}
+
+func (r *MediaRepository) GetRendition(ctx context.Context, mediaID uint, name, format string) (*models.MediaRendition, error) {
+	var rd models.MediaRendition
+	if err := r.db.WithContext(ctx).Where("media_id = ? AND name = ? AND format = ?", mediaID, name, format).Take(&rd).Error; err != nil {
+		return nil, err
+	}
+	return &rd, nil
+}
+
+// ClaimNext marks the oldest pending media (or one whose worker died while
+// processing it) as processing and returns it. Media that failed before
+// waits backoff after its last claim, four times longer per further failure.
+// SKIP LOCKED lets several workers across replicas poll concurrently.
+// Returns nil when idle.
+func (r *MediaRepository) ClaimNext(ctx context.Context, staleAfter, backoff time.Duration) (*models.Media, error) {
+	var m models.Media
+	res := r.db.WithContext(ctx).Raw(`
+		UPDATE media SET status = ?, attempts = attempts + 1, claimed_at = now()
+		WHERE id = (
+			SELECT id FROM media
+			WHERE (status = ? AND (attempts = 0 OR claimed_at < now() - make_interval(secs => ? * power(4, attempts - 1))))
+				OR (status = ? AND claimed_at < ?)
+			ORDER BY id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.MediaProcessing, models.MediaPending, backoff.Seconds(), models.MediaProcessing, time.Now().Add(-staleAfter)).Scan(&m)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || m.ID == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// SaveProcessed replaces the renditions of m and marks it ready.
+func (r *MediaRepository) SaveProcessed(ctx context.Context, m *models.Media, renditions []models.MediaRendition) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("media_id = ?", m.ID).Delete(&models.MediaRendition{}).Error; err != nil {
+			return err
+		}
+		if len(renditions) > 0 {
+			if err := tx.Create(&renditions).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Media{}).Where("id = ?", m.ID).Updates(map[string]interface{}{
+			"status":    models.MediaReady,
+			"error":     "",
+			"width":     m.Width,
+			"height":    m.Height,
+			"blur_hash": m.BlurHash,
+		}).Error
+	})
+}
+
+// MarkFailed records a processing error. Unless final, the media goes back to
+// pending and is retried once ClaimNext's backoff has passed.
+func (r *MediaRepository) MarkFailed(ctx context.Context, id uint, msg string, final bool) error {
+	status := models.MediaPending
+	if final {
+		status = models.MediaFailed
+	}
+	return r.db.WithContext(ctx).Model(&models.Media{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "error": msg}).Error
+}
+
+func (r *MediaRepository) GetByHash(ctx context.Context, tx *gorm.DB, hash string) (*models.Media, error) {
+	var m models.Media
+	if err := tx.WithContext(ctx).Where("hash = ?", hash).Take(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *MediaRepository) ListByPost(ctx context.Context, postID uint) ([]models.Media, error) {
+	var media []models.Media
+	err := r.db.WithContext(ctx).Preload("Renditions").
+		Joins("JOIN post_media pm ON pm.media_id = media.id").
+		Where("pm.post_id = ?", postID).
+		Order("pm.created_at, media.id").
+		Find(&media).Error
+	return media, err
+}
+
+func (r *MediaRepository) Link(ctx context.Context, tx *gorm.DB, postID, mediaID uint) error {
+	return tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.PostMedia{PostID: postID, MediaID: mediaID}).Error
+}
+
+func (r *MediaRepository) Unlink(ctx context.Context, postID, mediaID uint) error {
+	return r.db.WithContext(ctx).Where("post_id = ? AND media_id = ?", postID, mediaID).Delete(&models.PostMedia{}).Error
+}
+
+// UnlinkPost removes every link of postID inside tx and returns the media IDs it had.
+func (r *MediaRepository) UnlinkPost(ctx context.Context, tx *gorm.DB, postID uint) ([]uint, error) {
+	var links []models.PostMedia
+	if err := tx.WithContext(ctx).Clauses(clause.Returning{}).Where("post_id = ?", postID).Delete(&links).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.MediaID)
+	}
+	return ids, nil
+}
+
+// DeleteOrphan deletes media id inside tx if no post links to it and it was
+// last uploaded more than minAge ago, and reports whether it did. The check
+// and the delete are one statement so a concurrent link either lands first
+// and keeps the row, or fails afterwards.
+func (r *MediaRepository) DeleteOrphan(ctx context.Context, tx *gorm.DB, id uint, minAge time.Duration) (bool, error) {
+	res := tx.WithContext(ctx).
+		Where("id = ? AND uploaded_at < ? AND NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = media.id)", id, time.Now().Add(-minAge)).
+		Delete(&models.Media{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Unattached returns up to limit media that no post links to and that were
+// last uploaded more than minAge ago, oldest first.
+func (r *MediaRepository) Unattached(ctx context.Context, minAge time.Duration, limit int) ([]models.Media, error) {
+	var media []models.Media
+	err := r.db.WithContext(ctx).
+		Where("uploaded_at < ? AND NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = media.id)", time.Now().Add(-minAge)).
+		Order("uploaded_at").Limit(limit).
+		Find(&media).Error
+	return media, err
+}
@@ -2,43 +2,186 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/example/blog-service/internal/models"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type PostRepository struct{ db *gorm.DB }
+// ErrSlugConflict is returned by Create and Update when another transaction
+// took the slug after SlugTaken said it was free. The transaction is
+// aborted; retry it to pick another slug.
+var ErrSlugConflict = errors.New("slug taken concurrently")
 
-func NewPostRepository(db *gorm.DB) *PostRepository { return &PostRepository{db: db} }
+// slugConflict turns a unique violation on posts.slug into ErrSlugConflict.
+func slugConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_posts_slug" {
+		return ErrSlugConflict
+	}
+	return err
+}
+
+// Reader picks the connection for a read that tolerates replication lag;
+// see db.Database.Reader.
+type Reader func(ctx context.Context) *gorm.DB
+
+// PostRepository reads GetByID, SearchByTag and the listings through read,
+// which may pick a replica; everything else uses the primary.
+type PostRepository struct {
+	db   *gorm.DB
+	read Reader
+}
+
+// NewPostRepository reads everything from db when read is nil.
+func NewPostRepository(db *gorm.DB, read Reader) *PostRepository {
+	if read == nil {
+		read = func(context.Context) *gorm.DB { return db }
+	}
+	return &PostRepository{db: db, read: read}
+}
 
 func (r *PostRepository) Create(ctx context.Context, tx *gorm.DB, p *models.Post) error {
-	return tx.WithContext(ctx).Create(p).Error
+	return slugConflict(tx.WithContext(ctx).Create(p).Error)
 }
 
-func (r *PostRepository) Update(ctx context.Context, p *models.Post) error {
-	return r.db.WithContext(ctx).Model(&models.Post{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
-		"title":   p.Title,
-		"content": p.Content,
-		"tags":    p.Tags,
-	}).Error
+func (r *PostRepository) Update(ctx context.Context, tx *gorm.DB, p *models.Post) error {
+	return slugConflict(tx.WithContext(ctx).Model(&models.Post{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+		"title":          p.Title,
+		"slug":           p.Slug,
+		"content":        p.Content,
+		"content_format": p.ContentFormat,
+		"content_html":   p.ContentHTML,
+		"tags":           p.Tags,
+		"cover_media_id": p.CoverMediaID,
+		"author":         p.Author,
+		"status":         p.Status,
+		"published_at":   p.PublishedAt,
+
+		"excerpt":              p.Excerpt,
+		"word_count":           p.WordCount,
+		"reading_time_minutes": p.ReadingTimeMinutes,
+		"toc":                  p.TOC,
+	}).Error)
 }
 
 func (r *PostRepository) GetByID(ctx context.Context, id uint) (*models.Post, error) {
 	var post models.Post
-	if err := r.db.WithContext(ctx).First(&post, id).Error; err != nil { return nil, err }
+	if err := r.read(ctx).WithContext(ctx).First(&post, id).Error; err != nil { return nil, err }
 	return &post, nil
 }
 
-func (r *PostRepository) SearchByTag(ctx context.Context, tag string) ([]models.Post, error) {
-	var posts []models.Post
+// GetForUpdate loads a post inside tx and row-locks it until the transaction ends.
+func (r *PostRepository) GetForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Post, error) {
+	var post models.Post
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, id).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *PostRepository) SearchByTag(ctx context.Context, tag string) ([]models.PostSummary, error) {
+	var posts []models.PostSummary
 	// tags @> ARRAY[tag]::text[] uses GIN index
-	if err := r.db.WithContext(ctx).Where("tags @> ARRAY[?]::text[]", tag).Order("id DESC").Find(&posts).Error; err != nil {
+	if err := r.read(ctx).WithContext(ctx).Model(&models.Post{}).Where("tags @> ARRAY[?]::text[] AND status = ?", tag, models.PostPublished).Order("id DESC").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// ListAfter returns up to limit posts with IDs above afterID, in ID order,
+// for walking the whole table in batches.
+func (r *PostRepository) ListAfter(ctx context.Context, afterID uint, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	if err := r.read(ctx).WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&posts).Error; err != nil {
 		return nil, err
 	}
 	return posts, nil
 }
 
-func (r *PostRepository) LogActivity(ctx context.Context, tx *gorm.DB, action string, postID uint) error {
-	log := models.ActivityLog{Action: action, PostID: postID}
-	return tx.WithContext(ctx).Create(&log).Error
-} 
\ No newline at end of file
+// LatestPublished returns the newest published posts, optionally limited to a tag or an author.
+func (r *PostRepository) LatestPublished(ctx context.Context, tag, author string, limit int) ([]models.Post, error) {
+	q := r.read(ctx).WithContext(ctx).Where("status = ?", models.PostPublished)
+	if tag != "" {
+		q = q.Where("tags @> ARRAY[?]::text[]", tag)
+	}
+	if author != "" {
+		q = q.Where("author = ?", author)
+	}
+	var posts []models.Post
+	if err := q.Order("COALESCE(published_at, created_at) DESC, id DESC").Limit(limit).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// SlugTaken reports whether slug is used, currently or historically, by any post other than postID.
+func (r *PostRepository) SlugTaken(ctx context.Context, tx *gorm.DB, slug string, postID uint) (bool, error) {
+	var n int64
+	if err := tx.WithContext(ctx).Model(&models.Post{}).Where("slug = ? AND id <> ?", slug, postID).Count(&n).Error; err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+	if err := tx.WithContext(ctx).Model(&models.PostSlugHistory{}).Where("slug = ? AND post_id <> ?", slug, postID).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Slugs returns the current slug of a post followed by its historical ones.
+func (r *PostRepository) Slugs(ctx context.Context, tx *gorm.DB, postID uint) ([]string, error) {
+	var slugs []string
+	if err := tx.WithContext(ctx).Model(&models.Post{}).Where("id = ? AND slug IS NOT NULL", postID).Pluck("slug", &slugs).Error; err != nil {
+		return nil, err
+	}
+	var old []string
+	if err := tx.WithContext(ctx).Model(&models.PostSlugHistory{}).Where("post_id = ?", postID).Pluck("slug", &old).Error; err != nil {
+		return nil, err
+	}
+	return append(slugs, old...), nil
+}
+
+// Delete removes a post and its slug history.
+func (r *PostRepository) Delete(ctx context.Context, tx *gorm.DB, id uint) error {
+	if err := tx.WithContext(ctx).Where("post_id = ?", id).Delete(&models.PostSlugHistory{}).Error; err != nil {
+		return err
+	}
+	return tx.WithContext(ctx).Delete(&models.Post{}, id).Error
+}
+
+// GetIDBySlug resolves a current or historical slug to its post ID.
+func (r *PostRepository) GetIDBySlug(ctx context.Context, slug string) (uint, error) {
+	var post models.Post
+	err := r.db.WithContext(ctx).Select("id").Where("slug = ?", slug).Take(&post).Error
+	if err == nil {
+		return post.ID, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return 0, err
+	}
+	var h models.PostSlugHistory
+	if err := r.db.WithContext(ctx).Select("post_id").Where("slug = ?", slug).Take(&h).Error; err != nil {
+		return 0, err
+	}
+	return h.PostID, nil
+}
+
+// MoveSlug records oldSlug in the post's history and drops newSlug from it
+// in case the post is reclaiming a slug it used before.
+func (r *PostRepository) MoveSlug(ctx context.Context, tx *gorm.DB, postID uint, oldSlug, newSlug string) error {
+	if err := tx.WithContext(ctx).Where("post_id = ? AND slug = ?", postID, newSlug).Delete(&models.PostSlugHistory{}).Error; err != nil {
+		return err
+	}
+	if oldSlug == "" {
+		return nil
+	}
+	return tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PostSlugHistory{PostID: postID, Slug: oldSlug}).Error
+}
+
+func (r *PostRepository) LogActivity(ctx context.Context, tx *gorm.DB, entry *models.ActivityLog) error {
+	return tx.WithContext(ctx).Create(entry).Error
+}
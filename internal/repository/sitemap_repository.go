@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+// SitemapRepository reads published content for sitemaps. Listings go
+// through database cursors so a chunk never has to fit in memory as structs.
+type SitemapRepository struct{ db *gorm.DB }
+
+func NewSitemapRepository(db *gorm.DB) *SitemapRepository { return &SitemapRepository{db: db} }
+
+// Chunk is one sitemap file: its 0-based index and newest lastmod.
+type Chunk struct {
+	Index   int
+	LastMod time.Time
+}
+
+// SitemapEntry is one URL source: a post slug, a tag or an author.
+type SitemapEntry struct {
+	Key     string
+	LastMod time.Time
+}
+
+// Source SQL for each kind, each yielding (ord, key, lastmod); chunks are cut
+// in ord order, which is unique within a kind. Tags and authors take their
+// lastmod from their newest published post.
+var sitemapSources = map[string]string{
+	"posts": `SELECT id AS ord, slug AS key, updated_at AS last_mod FROM posts
+		WHERE status = @published AND slug IS NOT NULL`,
+	"tags": `SELECT t.tag AS ord, t.tag AS key, max(coalesce(p.published_at, p.updated_at)) AS last_mod
+		FROM posts p, unnest(p.tags) AS t(tag)
+		WHERE p.status = @published GROUP BY t.tag`,
+	"authors": `SELECT author AS ord, author AS key, max(coalesce(published_at, updated_at)) AS last_mod FROM posts
+		WHERE status = @published AND author <> '' GROUP BY author`,
+}
+
+func SitemapKinds() []string { return []string{"posts", "tags", "authors"} }
+
+// Chunks splits kind into files of size entries and returns each file's newest lastmod.
+func (r *SitemapRepository) Chunks(ctx context.Context, kind string, size int) ([]Chunk, error) {
+	var chunks []Chunk
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT (rn - 1) / @size AS "index", max(last_mod) AS last_mod
+		FROM (SELECT last_mod, row_number() OVER (ORDER BY ord) AS rn FROM (`+sitemapSources[kind]+`) src) numbered
+		GROUP BY 1 ORDER BY 1`,
+		map[string]interface{}{"size": size, "published": models.PostPublished}).Scan(&chunks).Error
+	return chunks, err
+}
+
+// Each streams the entries of one chunk to fn.
+func (r *SitemapRepository) Each(ctx context.Context, kind string, chunk, size int, fn func(SitemapEntry) error) error {
+	rows, err := r.db.WithContext(ctx).Raw(`SELECT key, last_mod FROM (`+sitemapSources[kind]+`) src ORDER BY ord OFFSET @offset LIMIT @limit`,
+		map[string]interface{}{"offset": chunk * size, "limit": size, "published": models.PostPublished}).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e SitemapEntry
+		if err := rows.Scan(&e.Key, &e.LastMod); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LatestActivityID is the high-water mark of writes; cached sitemaps built
+// at an older mark are stale.
+func (r *SitemapRepository) LatestActivityID(ctx context.Context) (uint, error) {
+	var id uint
+	err := r.db.WithContext(ctx).Model(&models.ActivityLog{}).Select("COALESCE(max(id), 0)").Scan(&id).Error
+	return id, err
+}
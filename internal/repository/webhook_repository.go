@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+type WebhookRepository struct{ db *gorm.DB }
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository { return &WebhookRepository{db: db} }
+
+func (r *WebhookRepository) Create(ctx context.Context, w *models.Webhook) error {
+	return r.db.WithContext(ctx).Create(w).Error
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id uint) (*models.Webhook, error) {
+	var w models.Webhook
+	if err := r.db.WithContext(ctx).First(&w, id).Error; err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *WebhookRepository) List(ctx context.Context) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	err := r.db.WithContext(ctx).Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+// Update writes every mutable field of w, so an explicit false for Active sticks.
+func (r *WebhookRepository) Update(ctx context.Context, w *models.Webhook) error {
+	res := r.db.WithContext(ctx).Model(&models.Webhook{}).Where("id = ?", w.ID).Updates(map[string]interface{}{
+		"url":        w.URL,
+		"events":     w.Events,
+		"secret":     w.Secret,
+		"active":     w.Active,
+		"updated_at": time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Delete(&models.Webhook{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Enqueue queues event for every active webhook subscribed to it. It runs in
+// the caller's transaction, so deliveries exist if and only if the change
+// that caused them was committed.
+func (r *WebhookRepository) Enqueue(ctx context.Context, tx *gorm.DB, event string, payload []byte) error {
+	return tx.WithContext(ctx).Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT id, ?, ?, ?, 0, now(), now(), now()
+		FROM webhooks
+		WHERE active AND ? = ANY(events)`,
+		event, string(payload), models.DeliveryPending, event).Error
+}
+
+// ClaimNext marks the oldest due delivery (or one whose worker died while
+// sending it) as in progress and returns it with its webhook. Returns nil
+// when idle.
+func (r *WebhookRepository) ClaimNext(ctx context.Context, staleAfter time.Duration) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	res := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, claimed_at = now(), updated_at = now()
+		WHERE id = (
+			SELECT id FROM webhook_deliveries
+			WHERE (status = ? AND next_attempt_at <= now()) OR (status = ? AND claimed_at < ?)
+			ORDER BY next_attempt_at, id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.DeliveryInProgress, models.DeliveryPending, models.DeliveryInProgress, time.Now().Add(-staleAfter)).Scan(&d)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || d.ID == 0 {
+		return nil, nil
+	}
+	w, err := r.GetByID(ctx, d.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	d.Webhook = w
+	return &d, nil
+}
+
+// SaveAttempt records the outcome of the latest attempt at d. d.Status
+// decides whether it is done, retried at d.NextAttemptAt or given up.
+func (r *WebhookRepository) SaveAttempt(ctx context.Context, d *models.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"status":          d.Status,
+		"next_attempt_at": d.NextAttemptAt,
+		"response_code":   d.ResponseCode,
+		"response_body":   d.ResponseBody,
+		"error":           d.Error,
+		"duration_ms":     d.DurationMs,
+		"delivered_at":    d.DeliveredAt,
+		"updated_at":      time.Now(),
+	}).Error
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if err := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID).First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListDeliveries returns the newest deliveries of a webhook, optionally only
+// those with the given status.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID uint, status string, limit int) ([]models.WebhookDelivery, error) {
+	var out []models.WebhookDelivery
+	q := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Order("id DESC").Limit(limit).Find(&out).Error
+	return out, err
+}
+
+// Redeliver queues a fresh copy of a delivery, leaving the original in the
+// log untouched.
+func (r *WebhookRepository) Redeliver(ctx context.Context, d *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	again := models.WebhookDelivery{
+		WebhookID:     d.WebhookID,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := r.db.WithContext(ctx).Create(&again).Error; err != nil {
+		return nil, err
+	}
+	return &again, nil
+}
@@ -5,14 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
 	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/metrics"
+	"github.com/example/blog-service/internal/models"
 )
 
 type Elastic struct {
@@ -20,9 +24,18 @@ type Elastic struct {
 	Index  string
 }
 
-func NewElastic(cfg *config.Config) (*Elastic, error) {
+// NewElastic returns a client for ELASTICSEARCH_ADDR that records request
+// metrics in m, which may be nil, and traces requests as client spans.
+func NewElastic(cfg *config.Config, m *metrics.Metrics) (*Elastic, error) {
 	cfgES := elasticsearch.Config{
 		Addresses: []string{cfg.ElasticAddr},
+		Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return "elasticsearch " + operation(r) })),
+		Logger: esLogger{
+			log:     slog.Default().With("component", "elasticsearch"),
+			metrics: m,
+			slow:    time.Duration(cfg.ElasticSlowMs) * time.Millisecond,
+		},
 	}
 	if cfg.ElasticUsername != "" {
 		cfgES.Username = cfg.ElasticUsername
@@ -35,6 +48,15 @@ func NewElastic(cfg *config.Config) (*Elastic, error) {
 	return &Elastic{Client: client, Index: "posts"}, nil
 }
 
+// Ping checks that the cluster answers.
+func (e *Elastic) Ping(ctx context.Context) error {
+	res, err := e.Client.Ping(e.Client.Ping.WithContext(ctx))
+	if err != nil { return err }
+	defer res.Body.Close()
+	if res.IsError() { return fmt.Errorf("ping: %s", res.Status()) }
+	return nil
+}
+
 func (e *Elastic) EnsurePostsIndex(ctx context.Context) error {
 	res, err := e.Client.Indices.Exists([]string{e.Index})
 	if err != nil {
@@ -49,6 +71,9 @@ func (e *Elastic) EnsurePostsIndex(ctx context.Context) error {
 				"title":   map[string]string{"type": "text"},
 				"content": map[string]string{"type": "text"},
 				"tags":    map[string]string{"type": "keyword"},
+				"status":  map[string]string{"type": "keyword"},
+				"excerpt": map[string]interface{}{"type": "text", "index": false},
+				"toc":     map[string]interface{}{"type": "object", "enabled": false},
 			},
 		},
 	}
@@ -70,14 +95,45 @@ func (e *Elastic) IndexPost(ctx context.Context, id uint, doc map[string]interfa
 	return nil
 }
 
+func (e *Elastic) DeletePost(ctx context.Context, id uint) error {
+	req := esapi.DeleteRequest{Index: e.Index, DocumentID: fmt.Sprintf("%d", id), Refresh: "true"}
+	res, err := req.Do(ctx, e.Client)
+	if err != nil { return err }
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound { return fmt.Errorf("delete error: %s", res.String()) }
+	return nil
+}
+
+// publishedOnly keeps drafts, which are indexed too, out of search results.
+// Documents indexed before posts had a status have none; they were all
+// published, so they match as well until the next reindex.
+var publishedOnly = map[string]interface{}{
+	"bool": map[string]interface{}{
+		"should": []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"status": models.PostPublished}},
+			map[string]interface{}{"bool": map[string]interface{}{
+				"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "status"}},
+			}},
+		},
+		"minimum_should_match": 1,
+	},
+}
+
 func (e *Elastic) SearchPosts(ctx context.Context, query string) ([]map[string]interface{}, error) {
 	body := map[string]interface{}{
 		"query": map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":  query,
-				"fields": []string{"title", "content"},
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"multi_match": map[string]interface{}{
+						"query":  query,
+						"fields": []string{"title", "content"},
+					},
+				},
+				"filter": publishedOnly,
 			},
 		},
+		// listings use excerpt/word_count/toc; the full text stays in ES
+		"_source": map[string]interface{}{"excludes": []string{"content"}},
 	}
 	b, _ := json.Marshal(body)
 	res, err := e.Client.Search(e.Client.Search.WithContext(ctx), e.Client.Search.WithIndex(e.Index), e.Client.Search.WithBody(strings.NewReader(string(b))), e.Client.Search.WithTrackTotalHits(true), e.Client.Search.WithTimeout(10*time.Second))
@@ -119,10 +175,12 @@ func (e *Elastic) FindRelatedPosts(ctx context.Context, postID uint, tags []stri
 						"id": postID,
 					},
 				},
+				"filter":               publishedOnly,
 				"minimum_should_match": 1,
 			},
 		},
-		"size": limit,
+		"size":    limit,
+		"_source": map[string]interface{}{"excludes": []string{"content"}},
 	}
 
 	b, _ := json.Marshal(body)
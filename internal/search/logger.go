@@ -0,0 +1,81 @@
+package search
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/blog-service/internal/metrics"
+)
+
+// esLogger observes the Elasticsearch client's round trips. It records
+// their latency and outcome in metrics and logs them: failures (transport
+// errors and error statuses other than 404) are errors, requests slower
+// than slow are warnings and the rest are debug records.
+type esLogger struct {
+	log     *slog.Logger
+	metrics *metrics.Metrics
+	slow    time.Duration
+}
+
+func (l esLogger) LogRoundTrip(req *http.Request, res *http.Response, err error, start time.Time, dur time.Duration) error {
+	if req == nil {
+		return nil
+	}
+	failed := err != nil || (res != nil && res.StatusCode >= 400 && res.StatusCode != http.StatusNotFound)
+	l.metrics.ElasticRequest(operation(req), dur, failed)
+
+	level, msg := slog.LevelDebug, "elasticsearch request"
+	switch {
+	case failed:
+		level, msg = slog.LevelError, "elasticsearch request failed"
+	case l.slow > 0 && dur > l.slow:
+		level, msg = slog.LevelWarn, "slow elasticsearch request"
+	}
+	if !l.log.Enabled(req.Context(), level) {
+		return nil
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Float64("duration_ms", float64(dur.Microseconds())/1000),
+	}
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.log.LogAttrs(req.Context(), level, msg, attrs...)
+	return nil
+}
+
+func (esLogger) RequestBodyEnabled() bool  { return false }
+func (esLogger) ResponseBodyEnabled() bool { return false }
+
+// operation names the API a request calls without the index name or
+// document ID, to keep metric labels bounded.
+func operation(req *http.Request) string {
+	for _, seg := range strings.Split(strings.Trim(req.URL.Path, "/"), "/") {
+		switch seg {
+		case "_search":
+			return "search"
+		case "_doc":
+			switch req.Method {
+			case http.MethodDelete:
+				return "delete"
+			case http.MethodGet, http.MethodHead:
+				return "get"
+			}
+			return "index"
+		}
+	}
+	switch req.Method {
+	case http.MethodHead:
+		return "index_exists"
+	case http.MethodPut:
+		return "create_index"
+	}
+	return "other"
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/models"
+)
+
+const (
+	activityMaintenanceInterval = 6 * time.Hour
+	activityMaintenanceLockKey  = 0x72657465 // "rete"
+)
+
+// ActivityRetention keeps activity_logs partitions ahead of time and moves
+// partitions past the retention period into gzipped NDJSON files.
+type ActivityRetention struct {
+	db     *db.Database
+	months int
+	ahead  int
+	dir    string
+}
+
+func NewActivityRetention(cfg *config.Config, database *db.Database) *ActivityRetention {
+	return &ActivityRetention{
+		db:     database,
+		months: cfg.ActivityRetentionMonths,
+		ahead:  cfg.ActivityPartitionsAhead,
+		dir:    cfg.ActivityArchiveDir,
+	}
+}
+
+// RetentionReport is what one run did.
+type RetentionReport struct {
+	Skipped  bool     `json:"skipped,omitempty"`
+	Archived []string `json:"archived"`
+	Files    []string `json:"files"`
+}
+
+// Run performs one maintenance pass now, then one every few hours until ctx
+// is cancelled.
+func (r *ActivityRetention) Run(ctx context.Context) {
+	ticker := time.NewTicker(activityMaintenanceInterval)
+	defer ticker.Stop()
+	for {
+		if rep, err := r.RunOnce(ctx, time.Now(), false); err != nil {
+			slog.Error("activity retention", "error", err)
+		} else if len(rep.Archived) > 0 {
+			slog.Info("activity retention: archived partitions", "partitions", rep.Archived)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates upcoming partitions and archives every partition whose
+// month ended more than the retention period before now. With dryRun it only
+// reports which partitions would be archived. Only one replica works at a
+// time; the others report Skipped.
+func (r *ActivityRetention) RunOnce(ctx context.Context, now time.Time, dryRun bool) (*RetentionReport, error) {
+	rep := &RetentionReport{Archived: []string{}, Files: []string{}}
+	ran, err := r.db.TryAdvisoryLock(ctx, activityMaintenanceLockKey, func() error {
+		if !dryRun {
+			month := db.MonthStart(now)
+			if err := r.db.EnsureActivityPartitions(ctx, month, month.AddDate(0, r.ahead, 0)); err != nil {
+				return fmt.Errorf("create partitions: %w", err)
+			}
+		}
+		if r.months <= 0 {
+			return nil
+		}
+		cutoff := db.MonthStart(now).AddDate(0, -r.months, 0)
+		parts, err := r.db.ActivityPartitions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range parts {
+			if !p.Month.Before(cutoff) {
+				continue
+			}
+			if dryRun {
+				rep.Archived = append(rep.Archived, p.Name)
+				continue
+			}
+			file, err := r.archive(ctx, p)
+			if err != nil {
+				return fmt.Errorf("archive %s: %w", p.Name, err)
+			}
+			rep.Archived = append(rep.Archived, p.Name)
+			rep.Files = append(rep.Files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	rep.Skipped = !ran
+	return rep, nil
+}
+
+// archive detaches p, exports it and drops it. Each step can be repeated, so
+// a run that fails halfway is finished by the next one.
+func (r *ActivityRetention) archive(ctx context.Context, p db.ActivityPartition) (string, error) {
+	if p.Attached {
+		if err := r.db.DetachActivityPartition(ctx, p.Name); err != nil {
+			return "", err
+		}
+	}
+	file, err := r.export(ctx, p.Name)
+	if err != nil {
+		return "", err
+	}
+	return file, r.db.DropActivityPartition(ctx, p.Name)
+}
+
+// export writes the rows of table to <dir>/<table>.ndjson.gz via a temp file,
+// so a partial export never looks like a finished one.
+func (r *ActivityRetention) export(ctx context.Context, table string) (string, error) {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.dir, table+".ndjson.gz")
+	tmp, err := os.CreateTemp(r.dir, ".export-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	buf := bufio.NewWriter(tmp)
+	gz := gzip.NewWriter(buf)
+	if err := ExportActivity(ctx, r.db, table, gz); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if err := buf.Flush(); err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// ExportActivity streams every row of table (activity_logs or one of its
+// partitions) to w as one JSON object per line, oldest first. The rows come
+// from a single query that lasts as long as the export, so it runs without
+// DB_STATEMENT_TIMEOUT_MS.
+func ExportActivity(ctx context.Context, database *db.Database, table string, w io.Writer) error {
+	return database.WithoutStatementTimeout(ctx, func(tx *gorm.DB) error {
+		rows, err := tx.Table(table).Order("id").Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		enc := json.NewEncoder(w)
+		for rows.Next() {
+			var e models.ActivityLog
+			if err := tx.ScanRows(rows, &e); err != nil {
+				return err
+			}
+			if err := enc.Encode(&e); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
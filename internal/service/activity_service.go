@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	defaultActivityPage = 50
+	maxActivityPage     = 200
+)
+
+type ActivityService struct {
+	repo ActivityStore
+}
+
+func NewActivityService(repo ActivityStore) *ActivityService {
+	return &ActivityService{repo: repo}
+}
+
+type ActivityQuery struct {
+	PostID  uint
+	ActorID string
+	Action  string
+	From    time.Time
+	To      time.Time
+	Cursor  string
+	Limit   int
+}
+
+type ActivityPage struct {
+	Items      []models.ActivityLog `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// List returns matching entries newest first. NextCursor is set when there
+// are more; passing it back continues where the page ended, unaffected by
+// entries logged in between.
+func (s *ActivityService) List(ctx context.Context, q ActivityQuery) (*ActivityPage, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultActivityPage
+	}
+	if q.Limit > maxActivityPage {
+		q.Limit = maxActivityPage
+	}
+	f := repository.ActivityFilter{PostID: q.PostID, ActorID: q.ActorID, Action: q.Action, From: q.From, To: q.To}
+	if q.Cursor != "" {
+		before, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		f.BeforeID = before
+	}
+	items, err := s.repo.List(ctx, f, q.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &ActivityPage{Items: items}
+	if len(items) > q.Limit {
+		page.Items = items[:q.Limit]
+		page.NextCursor = encodeCursor(page.Items[q.Limit-1].ID)
+	}
+	return page, nil
+}
+
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(c string) (uint, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
+
+// auditedFields are the post fields whose changes are recorded.
+var auditedFields = []string{"title", "slug", "content", "content_format", "tags", "cover_media_id", "author", "status", "published_at"}
+
+func auditValues(p *models.Post) map[string]interface{} {
+	if p == nil {
+		return map[string]interface{}{}
+	}
+	var publishedAt interface{}
+	if p.PublishedAt != nil {
+		publishedAt = p.PublishedAt.UTC()
+	}
+	var cover interface{}
+	if p.CoverMediaID != nil {
+		cover = *p.CoverMediaID
+	}
+	tags := []string(p.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	return map[string]interface{}{
+		"title":          p.Title,
+		"slug":           p.Slug,
+		"content":        p.Content,
+		"content_format": p.ContentFormat,
+		"tags":           tags,
+		"cover_media_id": cover,
+		"author":         p.Author,
+		"status":         p.Status,
+		"published_at":   publishedAt,
+	}
+}
+
+// postChanges diffs two versions of a post; nil stands for "did not exist".
+// Only the given fields are compared, or all audited fields if none are given.
+func postChanges(before, after *models.Post, fields ...string) models.Changes {
+	if len(fields) == 0 {
+		fields = auditedFields
+	}
+	b, a := auditValues(before), auditValues(after)
+	changes := models.Changes{}
+	for _, f := range fields {
+		bv, av := b[f], a[f]
+		if before != nil && after != nil && sameJSON(bv, av) {
+			continue
+		}
+		changes[f] = models.FieldChange{Before: bv, After: av}
+	}
+	return changes
+}
+
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
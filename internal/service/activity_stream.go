@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/models"
+)
+
+var ErrInvalidEventID = errors.New("invalid Last-Event-ID")
+
+const (
+	activityStreamKey     = "activity:stream"
+	activityChannel       = "activity:events"
+	activityStreamMaxLen  = 10000
+	activityReplayBatch   = 500
+	activitySubscriberBuf = 64
+	// how many delivered IDs a follower remembers to tell events published
+	// late by another replica from duplicates
+	activitySeenIDs = 1024
+)
+
+// ActivityEvent is an activity log entry as pushed to live subscribers. ID is
+// its Redis stream ID, which clients send back as Last-Event-ID to resume.
+type ActivityEvent struct {
+	ID    string
+	Entry models.ActivityLog
+}
+
+type streamMessage struct {
+	ID    string          `json:"id"`
+	Entry json.RawMessage `json:"entry"`
+}
+
+// ActivityStreamFilter selects events; zero fields match everything.
+type ActivityStreamFilter struct {
+	PostID  uint
+	Actions []string
+}
+
+func (f ActivityStreamFilter) match(e *models.ActivityLog) bool {
+	if f.PostID != 0 && e.PostID != f.PostID {
+		return false
+	}
+	if len(f.Actions) == 0 {
+		return true
+	}
+	for _, a := range f.Actions {
+		if a == e.Action {
+			return true
+		}
+	}
+	return false
+}
+
+// ActivityStream pushes activity log entries to live subscribers on every
+// replica. Entries are appended to a capped Redis stream (for resumption)
+// and announced on a Pub/Sub channel. Each process holds a single
+// subscription to that channel, opened while it has listeners, and fans
+// messages out to them locally.
+type ActivityStream struct {
+	cache cache.Broker
+
+	mu   sync.Mutex
+	subs map[chan ActivityEvent]struct{}
+	stop context.CancelFunc
+	// ready is closed once the listener's first subscribe attempt returns
+	ready chan struct{}
+}
+
+func NewActivityStream(cache cache.Broker) *ActivityStream {
+	return &ActivityStream{cache: cache, subs: make(map[chan ActivityEvent]struct{})}
+}
+
+// Publish announces committed entries. It is best effort: the activity log
+// table stays the source of truth, so failures are only logged.
+func (s *ActivityStream) Publish(ctx context.Context, entries ...*models.ActivityLog) {
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			slog.ErrorContext(ctx, "activity stream: encode entry", "error", err)
+			continue
+		}
+		id, err := s.cache.Append(ctx, activityStreamKey, activityStreamMaxLen, data)
+		if err != nil {
+			slog.WarnContext(ctx, "activity stream: append failed", "error", err)
+			continue
+		}
+		msg, _ := json.Marshal(streamMessage{ID: id, Entry: data})
+		if err := s.cache.Publish(ctx, activityChannel, msg); err != nil {
+			slog.WarnContext(ctx, "activity stream: publish failed", "error", err)
+		}
+	}
+}
+
+// Follow streams matching events until ctx is done. With a lastEventID it
+// first replays what the stream retained after that ID, then continues live
+// without gaps or duplicates. An event another replica announced late is
+// read back from the stream rather than dropped, so it can follow a newer one. The channel is closed when ctx ends or when the
+// subscriber falls too far behind; clients are expected to reconnect.
+func (s *ActivityStream) Follow(ctx context.Context, lastEventID string, f ActivityStreamFilter) (<-chan ActivityEvent, error) {
+	if lastEventID != "" {
+		if _, _, ok := parseStreamID(lastEventID); !ok {
+			return nil, ErrInvalidEventID
+		}
+	}
+	// subscribe before replaying so nothing published meanwhile is missed
+	live := s.subscribe(ctx)
+	out := make(chan ActivityEvent)
+	go func() {
+		defer close(out)
+		defer s.unsubscribe(live)
+
+		// last is the newest ID handled; seen remembers recent ones, since
+		// replicas publish independently and a live event may arrive after
+		// a newer one
+		last := lastEventID
+		seen := newRecentIDs(activitySeenIDs)
+		send := func(ev ActivityEvent) bool {
+			if last == "" || streamIDAfter(ev.ID, last) {
+				last = ev.ID
+			}
+			seen.add(ev.ID)
+			if !f.match(&ev.Entry) {
+				return true
+			}
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// replay sends what the stream holds after from up to until (or to
+		// its end when until is empty), skipping what was already sent
+		replay := func(from, until string) bool {
+			for {
+				batch, err := s.cache.ReadAfter(ctx, activityStreamKey, from, activityReplayBatch)
+				if err != nil {
+					slog.WarnContext(ctx, "activity stream: replay failed", "error", err)
+					return true
+				}
+				for _, m := range batch {
+					if until != "" && streamIDAfter(m.ID, until) {
+						return true
+					}
+					from = m.ID
+					var ev ActivityEvent
+					if seen.has(m.ID) || json.Unmarshal(m.Data, &ev.Entry) != nil {
+						continue
+					}
+					ev.ID = m.ID
+					if !send(ev) {
+						return false
+					}
+				}
+				if len(batch) < activityReplayBatch {
+					return true
+				}
+			}
+		}
+
+		if last != "" && !replay(last, "") {
+			return
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-live:
+				if !ok {
+					return
+				}
+				switch {
+				case last == "" || streamIDAfter(ev.ID, last):
+					if !send(ev) {
+						return
+					}
+				case seen.has(ev.ID) || (lastEventID != "" && !streamIDAfter(ev.ID, lastEventID)):
+					// already sent, or the client had it before reconnecting
+				default:
+					// published late: re-read from just before it, which
+					// also picks up anything else still in flight
+					if !replay(streamIDBefore(ev.ID), last) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// recentIDs is a bounded set of stream IDs that forgets the oldest first.
+type recentIDs struct {
+	max   int
+	order []string
+	set   map[string]struct{}
+}
+
+func newRecentIDs(max int) *recentIDs {
+	return &recentIDs{max: max, set: make(map[string]struct{}, max)}
+}
+
+func (r *recentIDs) add(id string) {
+	if r.has(id) {
+		return
+	}
+	r.set[id] = struct{}{}
+	r.order = append(r.order, id)
+	if len(r.order) > r.max {
+		delete(r.set, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *recentIDs) has(id string) bool {
+	_, ok := r.set[id]
+	return ok
+}
+
+// subscribe registers a local subscriber and returns once the shared Pub/Sub
+// subscription is confirmed, so that anything published afterwards reaches
+// it. If subscribing fails it returns anyway; the listener keeps retrying.
+func (s *ActivityStream) subscribe(ctx context.Context) chan ActivityEvent {
+	ch := make(chan ActivityEvent, activitySubscriberBuf)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	if s.stop == nil {
+		lctx, cancel := context.WithCancel(context.Background())
+		s.stop = cancel
+		s.ready = make(chan struct{})
+		go s.listen(lctx, s.ready)
+	}
+	ready := s.ready
+	s.mu.Unlock()
+	select {
+	case <-ready:
+	case <-ctx.Done():
+	}
+	return ch
+}
+
+func (s *ActivityStream) unsubscribe(ch chan ActivityEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	if len(s.subs) == 0 && s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+}
+
+// listen relays the Pub/Sub channel to local subscribers, resubscribing
+// after errors, until ctx is cancelled. It closes ready after the first
+// subscribe attempt.
+func (s *ActivityStream) listen(ctx context.Context, ready chan struct{}) {
+	for ctx.Err() == nil {
+		msgs, err := s.cache.Subscribe(ctx, activityChannel)
+		if ready != nil {
+			close(ready)
+			ready = nil
+		}
+		if err != nil {
+			slog.Warn("activity stream: subscribe failed", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for data := range msgs {
+			var m streamMessage
+			var ev ActivityEvent
+			if err := json.Unmarshal(data, &m); err != nil || json.Unmarshal(m.Entry, &ev.Entry) != nil {
+				continue
+			}
+			ev.ID = m.ID
+			s.broadcast(ev)
+		}
+	}
+}
+
+// broadcast never blocks: a subscriber whose buffer is full is dropped and
+// can resume from its last event ID.
+func (s *ActivityStream) broadcast(ev ActivityEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func parseStreamID(id string) (ms, seq uint64, ok bool) {
+	a, b, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err1 := strconv.ParseUint(a, 10, 64)
+	seq, err2 := strconv.ParseUint(b, 10, 64)
+	return ms, seq, err1 == nil && err2 == nil
+}
+
+// streamIDBefore returns the greatest stream ID that comes before id, so
+// reading after it starts at id.
+func streamIDBefore(id string) string {
+	ms, seq, _ := parseStreamID(id)
+	if seq > 0 {
+		return fmt.Sprintf("%d-%d", ms, seq-1)
+	}
+	if ms == 0 {
+		return "0-0"
+	}
+	return fmt.Sprintf("%d-%d", ms-1, uint64(math.MaxUint64))
+}
+
+// streamIDAfter reports whether stream ID a comes after b.
+func streamIDAfter(a, b string) bool {
+	ams, aseq, _ := parseStreamID(a)
+	bms, bseq, _ := parseStreamID(b)
+	return ams > bms || (ams == bms && aseq > bseq)
+}
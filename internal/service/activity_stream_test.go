@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/models"
+)
+
+func TestStreamIDBefore(t *testing.T) {
+	for id, want := range map[string]string{
+		"1700000000000-3": "1700000000000-2",
+		"1700000000000-0": "1699999999999-18446744073709551615",
+	} {
+		if got := streamIDBefore(id); got != want {
+			t.Errorf("streamIDBefore(%s) = %s, want %s", id, got, want)
+		}
+	}
+}
+
+// Replicas publish independently, so a live event can arrive after a newer
+// one. Follow must still deliver it, once.
+func TestFollowDeliversLateEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem := cache.NewMemory(&config.Config{CacheMemoryMaxEntries: 100})
+	s := NewActivityStream(mem)
+
+	events := make([]ActivityEvent, 3)
+	for i, action := range []string{"new_post", "update_post", "delete_post"} {
+		events[i].Entry = models.ActivityLog{Action: action}
+		data, _ := json.Marshal(events[i].Entry)
+		id, err := mem.Append(ctx, activityStreamKey, 100, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events[i].ID = id
+	}
+
+	out, err := s.Follow(ctx, "", ActivityStreamFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// update_post overtakes new_post, and update_post is announced twice
+	for _, i := range []int{1, 0, 1, 2} {
+		s.broadcast(events[i])
+	}
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case ev := <-out:
+			got = append(got, ev.Entry.Action)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %v, then nothing", got)
+		}
+	}
+	want := []string{"update_post", "new_post", "delete_post"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	select {
+	case ev := <-out:
+		t.Errorf("unexpected %s", ev.Entry.Action)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// slowBroker takes a while to confirm subscriptions, like Redis does, and
+// calls afterRead once after the first ReadAfter.
+type slowBroker struct {
+	*cache.Memory
+	afterRead func()
+}
+
+func (b *slowBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	time.Sleep(50 * time.Millisecond)
+	return b.Memory.Subscribe(ctx, channel)
+}
+
+func (b *slowBroker) ReadAfter(ctx context.Context, stream, afterID string, count int64) ([]cache.StreamEntry, error) {
+	out, err := b.Memory.ReadAfter(ctx, stream, afterID, count)
+	if f := b.afterRead; f != nil {
+		b.afterRead = nil
+		f()
+	}
+	return out, err
+}
+
+// An event published between subscribing and the replay reading the stream
+// must still arrive live: Follow may not replay before the subscription holds.
+func TestFollowPublishDuringReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := &slowBroker{Memory: cache.NewMemory(&config.Config{CacheMemoryMaxEntries: 100})}
+	s := NewActivityStream(b)
+
+	data, _ := json.Marshal(models.ActivityLog{Action: "new_post"})
+	first, err := b.Append(ctx, activityStreamKey, 100, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.afterRead = func() { s.Publish(ctx, &models.ActivityLog{Action: "update_post"}) }
+
+	out, err := s.Follow(ctx, streamIDBefore(first), ActivityStreamFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"new_post", "update_post"} {
+		select {
+		case ev := <-out:
+			if ev.Entry.Action != want {
+				t.Fatalf("got %s, want %s", ev.Entry.Action, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %s", want)
+		}
+	}
+}
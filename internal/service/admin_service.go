@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+)
+
+var (
+	ErrInvalidAdmin = errors.New("invalid admin")
+	ErrAdminExists  = repository.ErrAdminExists
+)
+
+const maxActorIDLen = 100
+
+// AdminService manages the actors allowed to write when REQUIRE_ADMIN_ACTOR
+// is on. Authentication stays with the gateway; this only decides which of
+// the X-Actor-IDs it passes may change content.
+type AdminService struct {
+	repo AdminStore
+}
+
+func NewAdminService(repo AdminStore) *AdminService {
+	return &AdminService{repo: repo}
+}
+
+// Create registers id, the X-Actor-ID of the new admin.
+func (s *AdminService) Create(ctx context.Context, id, name string) (*models.Admin, error) {
+	id, name = strings.TrimSpace(id), strings.TrimSpace(name)
+	switch {
+	case id == "":
+		return nil, fmt.Errorf("%w: id is required", ErrInvalidAdmin)
+	case len(id) > maxActorIDLen:
+		return nil, fmt.Errorf("%w: id is longer than %d bytes", ErrInvalidAdmin, maxActorIDLen)
+	case len(name) > 255:
+		return nil, fmt.Errorf("%w: name is longer than 255 bytes", ErrInvalidAdmin)
+	}
+	a := &models.Admin{ID: id, Name: name}
+	if err := s.repo.Create(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// IsAdmin reports whether the actor id is a registered admin.
+func (s *AdminService) IsAdmin(ctx context.Context, id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	return s.repo.Exists(ctx, id)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/metrics"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+	"github.com/example/blog-service/internal/search"
+	"github.com/example/blog-service/internal/storage"
+)
+
+// Transactor runs fn in a database transaction. Repository methods that take
+// a tx are handed the one fn receives.
+type Transactor interface {
+	Transaction(fn func(tx *gorm.DB) error) error
+}
+
+// PostStore is the persistence PostService needs; see repository.PostRepository.
+type PostStore interface {
+	Create(ctx context.Context, tx *gorm.DB, p *models.Post) error
+	Update(ctx context.Context, tx *gorm.DB, p *models.Post) error
+	GetByID(ctx context.Context, id uint) (*models.Post, error)
+	GetForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Post, error)
+	SearchByTag(ctx context.Context, tag string) ([]models.PostSummary, error)
+	ListAfter(ctx context.Context, afterID uint, limit int) ([]models.Post, error)
+	SlugTaken(ctx context.Context, tx *gorm.DB, slug string, postID uint) (bool, error)
+	Slugs(ctx context.Context, tx *gorm.DB, postID uint) ([]string, error)
+	Delete(ctx context.Context, tx *gorm.DB, id uint) error
+	GetIDBySlug(ctx context.Context, slug string) (uint, error)
+	MoveSlug(ctx context.Context, tx *gorm.DB, postID uint, oldSlug, newSlug string) error
+	LogActivity(ctx context.Context, tx *gorm.DB, entry *models.ActivityLog) error
+}
+
+// PostMediaStore checks cover media and maintains post-media links.
+type PostMediaStore interface {
+	GetByID(ctx context.Context, id uint) (*models.Media, error)
+	Link(ctx context.Context, tx *gorm.DB, postID, mediaID uint) error
+	UnlinkPost(ctx context.Context, tx *gorm.DB, postID uint) ([]uint, error)
+}
+
+// WebhookQueue queues webhook deliveries inside the writing transaction.
+type WebhookQueue interface {
+	Enqueue(ctx context.Context, tx *gorm.DB, event string, payload []byte) error
+}
+
+// SearchIndex is the full-text index of posts; see search.Elastic.
+type SearchIndex interface {
+	IndexPost(ctx context.Context, id uint, doc map[string]interface{}) error
+	DeletePost(ctx context.Context, id uint) error
+	SearchPosts(ctx context.Context, query string) ([]map[string]interface{}, error)
+	FindRelatedPosts(ctx context.Context, postID uint, tags []string, limit int) ([]map[string]interface{}, error)
+}
+
+// MediaLibrary is the part of MediaService posts depend on.
+type MediaLibrary interface {
+	Covers(ctx context.Context, ids []uint) (map[uint]*models.CoverImage, error)
+	CollectGarbage(ctx context.Context, ids []uint)
+}
+
+// ActivityPublisher announces committed activity log entries.
+type ActivityPublisher interface {
+	Publish(ctx context.Context, entries ...*models.ActivityLog)
+}
+
+// PostMetrics counts post writes and searches. *metrics.Metrics implements
+// it; without one nothing is counted.
+type PostMetrics interface {
+	PostCreated()
+	PostUpdated()
+	PostDeleted()
+	// Searched records a search of kind "tag" or "fulltext" and how many
+	// results it returned.
+	Searched(kind string, results int)
+}
+
+// PostLookup finds posts that media is attached to.
+type PostLookup interface {
+	GetByID(ctx context.Context, id uint) (*models.Post, error)
+}
+
+// MediaStore is the persistence MediaService needs; see repository.MediaRepository.
+type MediaStore interface {
+	PostMediaStore
+	LockHash(ctx context.Context, tx *gorm.DB, hash string) error
+	Create(ctx context.Context, tx *gorm.DB, m *models.Media) error
+	GetByIDs(ctx context.Context, ids []uint) ([]models.Media, error)
+	GetByHash(ctx context.Context, tx *gorm.DB, hash string) (*models.Media, error)
+	GetRendition(ctx context.Context, mediaID uint, name, format string) (*models.MediaRendition, error)
+	ListByPost(ctx context.Context, postID uint) ([]models.Media, error)
+	Unlink(ctx context.Context, postID, mediaID uint) error
+	DeleteOrphan(ctx context.Context, tx *gorm.DB, id uint, minAge time.Duration) (bool, error)
+	Unattached(ctx context.Context, minAge time.Duration, limit int) ([]models.Media, error)
+}
+
+// FeedStore lists the posts that go into feeds.
+type FeedStore interface {
+	LatestPublished(ctx context.Context, tag, author string, limit int) ([]models.Post, error)
+}
+
+// SitemapStore lists sitemap entries; see repository.SitemapRepository.
+type SitemapStore interface {
+	Chunks(ctx context.Context, kind string, size int) ([]repository.Chunk, error)
+	Each(ctx context.Context, kind string, chunk, size int, fn func(repository.SitemapEntry) error) error
+	LatestActivityID(ctx context.Context) (uint, error)
+}
+
+// WebhookStore manages webhooks and their delivery log.
+type WebhookStore interface {
+	WebhookQueue
+	Create(ctx context.Context, w *models.Webhook) error
+	GetByID(ctx context.Context, id uint) (*models.Webhook, error)
+	List(ctx context.Context) ([]models.Webhook, error)
+	Update(ctx context.Context, w *models.Webhook) error
+	Delete(ctx context.Context, id uint) error
+	GetDelivery(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, webhookID uint, status string, limit int) ([]models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, d *models.WebhookDelivery) (*models.WebhookDelivery, error)
+}
+
+// AdminStore holds the actors allowed to write.
+type AdminStore interface {
+	Create(ctx context.Context, a *models.Admin) error
+	Exists(ctx context.Context, id string) (bool, error)
+}
+
+// ActivityStore lists activity log entries.
+type ActivityStore interface {
+	List(ctx context.Context, f repository.ActivityFilter, limit int) ([]models.ActivityLog, error)
+}
+
+var (
+	_ Transactor        = (*db.Database)(nil)
+	_ PostStore         = (*repository.PostRepository)(nil)
+	_ PostMediaStore    = (*repository.MediaRepository)(nil)
+	_ WebhookQueue      = (*repository.WebhookRepository)(nil)
+	_ SearchIndex       = (*search.Elastic)(nil)
+	_ MediaLibrary      = (*MediaService)(nil)
+	_ ActivityPublisher = (*ActivityStream)(nil)
+	_ PostMetrics       = (*metrics.Metrics)(nil)
+	_ PostLookup        = (*repository.PostRepository)(nil)
+	_ MediaStore        = (*repository.MediaRepository)(nil)
+	_ FeedStore         = (*repository.PostRepository)(nil)
+	_ SitemapStore      = (*repository.SitemapRepository)(nil)
+	_ WebhookStore      = (*repository.WebhookRepository)(nil)
+	_ ActivityStore     = (*repository.ActivityRepository)(nil)
+	_ AdminStore        = (*repository.AdminRepository)(nil)
+)
+
+// PostDeps are the collaborators of a PostService. NewPostDeps wires the
+// production ones; tests substitute the fakes in internal/fakes.
+type PostDeps struct {
+	DB       Transactor
+	Cache    cache.Cache
+	Search   SearchIndex
+	Posts    PostStore
+	Media    PostMediaStore
+	Webhooks WebhookQueue
+	Library  MediaLibrary
+	Events   ActivityPublisher
+	Metrics  PostMetrics
+	// how long after a write cache fills of what it touched read from the
+	// primary; 0 without replicas
+	ReplicaLag time.Duration
+}
+
+func NewPostDeps(cfg *config.Config, database *db.Database, cache cache.Client, es *search.Elastic, media *MediaService) PostDeps {
+	return PostDeps{
+		DB:         database,
+		Cache:      cache,
+		Search:     es,
+		Posts:      repository.NewPostRepository(database.Gorm, database.Reader),
+		Media:      repository.NewMediaRepository(database.Gorm),
+		Webhooks:   repository.NewWebhookRepository(database.Gorm),
+		Library:    media,
+		Events:     NewActivityStream(cache),
+		ReplicaLag: replicaLag(cfg),
+	}
+}
+
+// MediaDeps are the collaborators of a MediaService; NewMediaDeps wires the
+// production ones.
+type MediaDeps struct {
+	DB    Transactor
+	Media MediaStore
+	Posts PostLookup
+	Store storage.Storage
+}
+
+func NewMediaDeps(database *db.Database, store storage.Storage) MediaDeps {
+	return MediaDeps{
+		DB:    database,
+		Media: repository.NewMediaRepository(database.Gorm),
+		// attaching media to a post that was just created must find it
+		Posts: repository.NewPostRepository(database.Gorm, nil),
+		Store: store,
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/feed"
+)
+
+type FeedService struct {
+	cache cache.Cache
+	repo  FeedStore
+	site  feed.Site
+	size  int
+	marks writeMarks
+}
+
+func NewFeedService(cfg *config.Config, posts FeedStore, cache cache.Cache) *FeedService {
+	return &FeedService{
+		cache: cache,
+		repo:  posts,
+		site:  feed.Site{Title: cfg.SiteTitle, BaseURL: cfg.PublicBaseURL},
+		size:  cfg.FeedSize,
+		marks: newWriteMarks(cfg, cache),
+	}
+}
+
+// Feed returns the rendered feed, from Redis when possible. PostService drops
+// the cached copies whenever a post in them is written. Misses read from a
+// replica unless that happened moments ago.
+func (s *FeedService) Feed(ctx context.Context, format string, scope feed.Scope) (*feed.Rendered, error) {
+	key := feed.CacheKey(format, scope)
+	var cached feed.Rendered
+	if found, err := s.cache.Get(ctx, key, &cached); err == nil && found {
+		return &cached, nil
+	}
+	posts, err := s.repo.LatestPublished(s.marks.reader(ctx, key), scope.Tag, scope.Author, s.size)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := feed.Render(s.site, format, scope, posts)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.cache.Set(ctx, key, rendered, 0)
+	return rendered, nil
+}
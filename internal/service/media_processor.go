@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/imageproc"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+	"github.com/example/blog-service/internal/storage"
+)
+
+const (
+	mediaMaxAttempts = 3
+	// a claim older than this is assumed to belong to a crashed worker
+	mediaStaleAfter = 10 * time.Minute
+	// a failed attempt is retried this long after it was claimed, four
+	// times longer after each further failure
+	mediaRetryBackoff = 30 * time.Second
+)
+
+// MediaProcessor is the background worker that turns pending uploads into
+// a metadata-free original, renditions and a blurhash. The upload itself is
+// kept as it is, so its hash keeps describing the stored bytes. Work is claimed from
+// the media table, so any number of replicas can run it side by side.
+type MediaProcessor struct {
+	repo        *repository.MediaRepository
+	store       storage.Storage
+	concurrency int
+	interval    time.Duration
+}
+
+func NewMediaProcessor(cfg *config.Config, database *db.Database, store storage.Storage) *MediaProcessor {
+	interval := time.Duration(cfg.MediaPollSec) * time.Second
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return &MediaProcessor{
+		repo:        repository.NewMediaRepository(database.Gorm),
+		store:       store,
+		concurrency: cfg.MediaWorkers,
+		interval:    interval,
+	}
+}
+
+// Run polls for work until ctx is cancelled.
+func (p *MediaProcessor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *MediaProcessor) loop(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		// drain the queue before going back to sleep
+		for {
+			worked, err := p.processNext(ctx)
+			if err != nil {
+				slog.Error("media processor", "error", err)
+			}
+			if !worked || ctx.Err() != nil {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *MediaProcessor) processNext(ctx context.Context) (bool, error) {
+	m, err := p.repo.ClaimNext(ctx, mediaStaleAfter, mediaRetryBackoff)
+	if err != nil || m == nil {
+		return false, err
+	}
+	if err := p.process(ctx, m); err != nil {
+		final := m.Attempts >= mediaMaxAttempts
+		if markErr := p.repo.MarkFailed(ctx, m.ID, err.Error(), final); markErr != nil {
+			return true, markErr
+		}
+		return true, fmt.Errorf("media %d (attempt %d): %w", m.ID, m.Attempts, err)
+	}
+	return true, nil
+}
+
+func (p *MediaProcessor) process(ctx context.Context, m *models.Media) error {
+	rc, err := p.store.Open(ctx, m.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	res, err := imageproc.Process(data)
+	if err != nil {
+		return err
+	}
+
+	// the stripped original is served in place of the upload, which still
+	// carries its EXIF. GIFs are served as uploaded.
+	outputs := res.Renditions
+	if res.Original != nil {
+		outputs = append([]imageproc.Output{*res.Original}, outputs...)
+	}
+	renditions := make([]models.MediaRendition, 0, len(outputs)+1)
+	if res.Original == nil {
+		renditions = append(renditions, models.MediaRendition{
+			MediaID:    m.ID,
+			Name:       originalRendition,
+			Format:     imageproc.FormatGIF,
+			StorageKey: m.StorageKey,
+			MimeType:   m.MimeType,
+			Hash:       m.Hash,
+			Width:      res.Width,
+			Height:     res.Height,
+			Size:       m.Size,
+		})
+	}
+	for _, out := range outputs {
+		key := renditionKey(m.Hash, out.Name, out.Format)
+		mime := imageproc.MimeTypes[out.Format]
+		if err := p.store.Put(ctx, key, bytes.NewReader(out.Data), int64(len(out.Data)), mime); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(out.Data)
+		renditions = append(renditions, models.MediaRendition{
+			MediaID:    m.ID,
+			Name:       out.Name,
+			Format:     out.Format,
+			StorageKey: key,
+			MimeType:   mime,
+			Hash:       hex.EncodeToString(sum[:]),
+			Width:      out.Width,
+			Height:     out.Height,
+			Size:       int64(len(out.Data)),
+		})
+	}
+
+	m.Width, m.Height, m.BlurHash = res.Width, res.Height, res.BlurHash
+	return p.repo.SaveProcessed(ctx, m, renditions)
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/imageproc"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/storage"
+)
+
+var (
+	ErrMediaTooLarge       = errors.New("file too large")
+	ErrUnsupportedMimeType = errors.New("unsupported media type")
+	ErrMediaNotReady       = errors.New("media is still being processed")
+	ErrMediaFailed         = errors.New("media could not be processed")
+)
+
+// originalRendition is the upload with its metadata stripped, served by
+// Open. The upload itself stays untouched under Media.StorageKey.
+const originalRendition = "original"
+
+var mimeExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+const (
+	// CollectGarbage spares media uploaded this recently: the upload may
+	// have handed it to a client that hasn't linked it yet. The sweep
+	// collects it later if it stays unlinked.
+	mediaGCGrace = 10 * time.Minute
+	// unlinked media is looked for this often, and at most this many at once
+	mediaSweepInterval = time.Hour
+	mediaSweepBatch    = 500
+)
+
+type MediaService struct {
+	db         Transactor
+	store      storage.Storage
+	repo       MediaStore
+	posts      PostLookup
+	maxBytes   int64
+	allowed    map[string]bool
+	unattached time.Duration
+}
+
+func NewMediaService(cfg *config.Config, d MediaDeps) *MediaService {
+	allowed := make(map[string]bool, len(cfg.MediaAllowedTypes))
+	for _, t := range cfg.MediaAllowedTypes {
+		allowed[t] = true
+	}
+	return &MediaService{
+		db:       d.DB,
+		store:    d.Store,
+		repo:     d.Media,
+		posts:    d.Posts,
+		maxBytes: int64(cfg.MediaMaxBytes),
+		allowed:  allowed,
+		// config.Load requires at least an hour
+		unattached: time.Duration(cfg.MediaUnattachedHours) * time.Hour,
+	}
+}
+
+func (s *MediaService) MaxBytes() int64 { return s.maxBytes }
+
+// Upload stores r (deduplicated by content hash) and links it to postID when non-zero.
+// The MIME type is sniffed from the bytes; the client-supplied type is ignored.
+// New media starts out pending; MediaProcessor strips metadata and builds the
+// renditions in the background.
+func (s *MediaService) Upload(ctx context.Context, name string, r io.Reader, postID uint) (*models.Media, error) {
+	if postID != 0 {
+		if _, err := s.posts.GetByID(ctx, postID); err != nil {
+			return nil, notFound(err)
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, ErrMediaTooLarge
+	}
+
+	mime := http.DetectContentType(data)
+	if !s.allowed[mime] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMimeType, mime)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	key := fmt.Sprintf("%s/%s/%s%s", hash[:2], hash[2:4], hash, mimeExtensions[mime])
+	var m *models.Media
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// garbage collection takes the same lock, so the row and the object
+		// can't be deleted between the lookup and the link
+		if err := s.repo.LockHash(ctx, tx, hash); err != nil {
+			return err
+		}
+		_, err := s.repo.GetByHash(ctx, tx, hash)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := s.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), mime); err != nil {
+				return fmt.Errorf("store media: %w", err)
+			}
+		} else if err != nil {
+			return err
+		}
+		m = &models.Media{Hash: hash, StorageKey: key, MimeType: mime, Size: int64(len(data)), OriginalName: name}
+		if err := s.repo.Create(ctx, tx, m); err != nil {
+			return err
+		}
+		if postID != 0 {
+			return s.repo.Link(ctx, tx, postID, m.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withURL(m), nil
+}
+
+func (s *MediaService) Get(ctx context.Context, id uint) (*models.Media, error) {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return withURL(m), nil
+}
+
+// Open returns the metadata-free original with a reader for its bytes. Media
+// isn't served until processing has stripped its metadata, and never if
+// processing failed for good.
+func (s *MediaService) Open(ctx context.Context, id uint) (*models.MediaRendition, io.ReadSeekCloser, error) {
+	m, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch m.Status {
+	case models.MediaReady:
+	case models.MediaFailed:
+		return nil, nil, ErrMediaFailed
+	default:
+		return nil, nil, ErrMediaNotReady
+	}
+	var rd *models.MediaRendition
+	for i := range m.Renditions {
+		if m.Renditions[i].Name == originalRendition {
+			rd = &m.Renditions[i]
+		}
+	}
+	if rd == nil {
+		// processed before the upload was kept, when the stripped bytes
+		// replaced it in place
+		rd = &models.MediaRendition{MediaID: m.ID, Name: originalRendition, StorageKey: m.StorageKey, MimeType: m.MimeType, Hash: m.Hash, CreatedAt: m.CreatedAt}
+	}
+	rc, err := s.store.Open(ctx, rd.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return rd, rc, nil
+}
+
+// OpenRendition returns a rendition such as "thumbnail"/"webp" with a reader for its bytes.
+func (s *MediaService) OpenRendition(ctx context.Context, id uint, name, format string) (*models.MediaRendition, io.ReadSeekCloser, error) {
+	rd, err := s.repo.GetRendition(ctx, id, name, format)
+	if err != nil {
+		return nil, nil, notFound(err)
+	}
+	rc, err := s.store.Open(ctx, rd.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return rd, rc, nil
+}
+
+// Covers returns listing thumbnails for the given media IDs, keyed by ID.
+// Media that hasn't been processed yet is left out.
+func (s *MediaService) Covers(ctx context.Context, ids []uint) (map[uint]*models.CoverImage, error) {
+	media, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	covers := make(map[uint]*models.CoverImage, len(media))
+	for i := range media {
+		m := withURL(&media[i])
+		if m.Status != models.MediaReady {
+			continue
+		}
+		cover := &models.CoverImage{MediaID: m.ID, Width: m.Width, Height: m.Height, BlurHash: m.BlurHash}
+		for _, rd := range m.Renditions {
+			if rd.Name == "thumbnail" && (cover.ThumbnailURL == "" || rd.Format == imageproc.FormatWebP) {
+				cover.ThumbnailURL = rd.URL
+			}
+		}
+		covers[m.ID] = cover
+	}
+	return covers, nil
+}
+
+func (s *MediaService) ListForPost(ctx context.Context, postID uint) ([]models.Media, error) {
+	media, err := s.repo.ListByPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range media {
+		withURL(&media[i])
+	}
+	return media, nil
+}
+
+func (s *MediaService) Attach(ctx context.Context, postID, mediaID uint) error {
+	if _, err := s.posts.GetByID(ctx, postID); err != nil {
+		return notFound(err)
+	}
+	if _, err := s.repo.GetByID(ctx, mediaID); err != nil {
+		return notFound(err)
+	}
+	return s.link(ctx, postID, mediaID)
+}
+
+func (s *MediaService) link(ctx context.Context, postID, mediaID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.repo.Link(ctx, tx, postID, mediaID)
+	})
+}
+
+func (s *MediaService) Detach(ctx context.Context, postID, mediaID uint) error {
+	if err := s.repo.Unlink(ctx, postID, mediaID); err != nil {
+		return err
+	}
+	s.CollectGarbage(ctx, []uint{mediaID})
+	return nil
+}
+
+// CollectGarbage removes those of ids that are no longer linked to any post,
+// both the rows and the stored objects. Failures are logged, not returned:
+// callers have already committed the change that orphaned the media.
+func (s *MediaService) CollectGarbage(ctx context.Context, ids []uint) {
+	media, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "media gc", "error", err)
+		return
+	}
+	s.collect(ctx, media, mediaGCGrace)
+}
+
+// SweepUnattached removes media that no post has linked to since it was
+// last uploaded more than MEDIA_UNATTACHED_HOURS ago, and returns how many
+// it removed.
+func (s *MediaService) SweepUnattached(ctx context.Context) (int, error) {
+	media, err := s.repo.Unattached(ctx, s.unattached, mediaSweepBatch)
+	if err != nil {
+		return 0, err
+	}
+	return s.collect(ctx, media, s.unattached), nil
+}
+
+// RunSweeper sweeps unattached media now, then every hour until ctx is
+// cancelled. Replicas may sweep concurrently; the hash locks keep them apart.
+func (s *MediaService) RunSweeper(ctx context.Context) {
+	ticker := time.NewTicker(mediaSweepInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.SweepUnattached(ctx); err != nil {
+			slog.Error("media sweep", "error", err)
+		} else if n > 0 {
+			slog.Info("media sweep: removed unattached media", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect deletes each of media that is unlinked and older than minAge,
+// holding its hash lock until the stored objects are gone too.
+func (s *MediaService) collect(ctx context.Context, media []models.Media, minAge time.Duration) int {
+	n := 0
+	for i := range media {
+		m := &media[i]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := s.repo.LockHash(ctx, tx, m.Hash); err != nil {
+				return err
+			}
+			deleted, err := s.repo.DeleteOrphan(ctx, tx, m.ID, minAge)
+			if err != nil || !deleted {
+				return err
+			}
+			n++
+			s.deleteObjects(ctx, m)
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "media gc", "media_id", m.ID, "error", err)
+		}
+	}
+	return n
+}
+
+func (s *MediaService) deleteObjects(ctx context.Context, m *models.Media) {
+	// rendition keys are derived from the hash, so no rows are needed to find them
+	keys := []string{m.StorageKey}
+	names := []string{originalRendition}
+	for _, spec := range imageproc.Renditions {
+		names = append(names, spec.Name)
+	}
+	for _, name := range names {
+		for format := range imageproc.Extensions {
+			keys = append(keys, renditionKey(m.Hash, name, format))
+		}
+	}
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "media gc: delete object failed", "key", key, "error", err)
+		}
+	}
+}
+
+func renditionKey(hash, name, format string) string {
+	return fmt.Sprintf("renditions/%s/%s%s", hash, name, imageproc.Extensions[format])
+}
+
+func withURL(m *models.Media) *models.Media {
+	m.URL = fmt.Sprintf("/media/%d/file", m.ID)
+	for i := range m.Renditions {
+		rd := &m.Renditions[i]
+		rd.URL = fmt.Sprintf("/media/%d/renditions/%s%s", m.ID, rd.Name, imageproc.Extensions[rd.Format])
+	}
+	return m
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/fakes"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/service"
+	"github.com/example/blog-service/internal/storage"
+)
+
+func newMediaService(t *testing.T) (*service.MediaService, *fakes.Media, storage.Storage) {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := fakes.NewMedia()
+	cfg := &config.Config{MediaMaxBytes: 1 << 20, MediaAllowedTypes: []string{"image/png"}, MediaUnattachedHours: 24}
+	svc := service.NewMediaService(cfg, service.MediaDeps{DB: fakes.Tx{}, Media: media, Posts: fakes.NewPosts(), Store: store})
+	return svc, media, store
+}
+
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCollectGarbageSparesFreshUploads(t *testing.T) {
+	svc, media, _ := newMediaService(t)
+	ctx := context.Background()
+	m, err := svc.Upload(ctx, "a.png", bytes.NewReader(pngBytes(t)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.CollectGarbage(ctx, []uint{m.ID})
+	if _, err := media.GetByID(ctx, m.ID); err != nil {
+		t.Errorf("just uploaded media collected: %v", err)
+	}
+
+	media.SetUploadedAt(m.ID, time.Now().Add(-time.Hour))
+	svc.CollectGarbage(ctx, []uint{m.ID})
+	if _, err := media.GetByID(ctx, m.ID); err == nil {
+		t.Error("orphaned media not collected")
+	}
+}
+
+func TestSweepUnattached(t *testing.T) {
+	svc, media, store := newMediaService(t)
+	ctx := context.Background()
+	data := pngBytes(t)
+	m, err := svc.Upload(ctx, "a.png", bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := media.Add(models.Media{Hash: "linked", StorageKey: "linked.png"})
+	if err := media.Link(ctx, nil, 1, linked); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := svc.SweepUnattached(ctx); err != nil || n != 0 {
+		t.Fatalf("sweep of a fresh upload = %d, %v; want 0", n, err)
+	}
+
+	// uploading the same bytes again restarts the clock
+	media.SetUploadedAt(m.ID, time.Now().Add(-48*time.Hour))
+	if _, err := svc.Upload(ctx, "b.png", bytes.NewReader(data), 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := svc.SweepUnattached(ctx); err != nil || n != 0 {
+		t.Fatalf("sweep after re-upload = %d, %v; want 0", n, err)
+	}
+
+	media.SetUploadedAt(m.ID, time.Now().Add(-25*time.Hour))
+	if n, err := svc.SweepUnattached(ctx); err != nil || n != 1 {
+		t.Fatalf("sweep = %d, %v; want 1", n, err)
+	}
+	if _, err := media.GetByID(ctx, m.ID); err == nil {
+		t.Error("unattached media not swept")
+	}
+	stored, _ := media.GetByID(ctx, linked)
+	if stored == nil {
+		t.Error("linked media swept")
+	}
+	if _, err := store.Open(ctx, m.StorageKey); err == nil {
+		t.Error("stored object not deleted")
+	}
+}
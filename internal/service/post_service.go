@@ -2,44 +2,96 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	"gorm.io/gorm"
 
+	"github.com/example/blog-service/internal/activity"
 	"github.com/example/blog-service/internal/cache"
 	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/feed"
+	"github.com/example/blog-service/internal/markup"
 	"github.com/example/blog-service/internal/models"
 	"github.com/example/blog-service/internal/repository"
-	"github.com/example/blog-service/internal/search"
+	"github.com/example/blog-service/internal/slug"
+)
+
+const (
+	maxSlugAttempts = 1000
+	slugRetries     = 3
+	excerptLength   = 280
+)
+
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidCoverMedia = errors.New("cover media not found")
 )
 
 type PostService struct {
-	db     *db.Database
-	cache  *cache.RedisClient
-	es     *search.Elastic
-	repo   *repository.PostRepository
+	db        Transactor
+	cache     cache.Cache
+	es        SearchIndex
+	repo      PostStore
+	mediaRepo PostMediaStore
+	hooks     WebhookQueue
+	media     MediaLibrary
+	events    ActivityPublisher
+	metrics   PostMetrics
+	marks     writeMarks
 }
 
-func NewPostService(database *db.Database, cache *cache.RedisClient, es *search.Elastic) *PostService {
+func NewPostService(d PostDeps) *PostService {
+	if d.Metrics == nil { d.Metrics = noMetrics{} }
 	return &PostService{
-		db:    database,
-		cache: cache,
-		es:   es,
-		repo: repository.NewPostRepository(database.Gorm),
+		db:        d.DB,
+		cache:     d.Cache,
+		es:        d.Search,
+		repo:      d.Posts,
+		mediaRepo: d.Media,
+		hooks:     d.Webhooks,
+		media:     d.Library,
+		events:    d.Events,
+		metrics:   d.Metrics,
+		marks:     writeMarks{cache: d.Cache, window: d.ReplicaLag},
 	}
 }
 
+type noMetrics struct{}
+
+func (noMetrics) PostCreated()         {}
+func (noMetrics) PostUpdated()         {}
+func (noMetrics) PostDeleted()         {}
+func (noMetrics) Searched(string, int) {}
+
 type CreatePostInput struct {
-	Title   string   `json:"title"`
-	Content string   `json:"content"`
-	Tags    []string `json:"tags"`
+	Title         string   `json:"title"`
+	Slug          string   `json:"slug"`
+	Content       string   `json:"content"`
+	ContentFormat string   `json:"content_format"`
+	Tags          []string `json:"tags"`
+	CoverMediaID  *uint    `json:"cover_media_id"`
+	Author        string   `json:"author"`
+	Status        string   `json:"status"`
 }
 
 type UpdatePostInput struct {
-	Title   string   `json:"title"`
-	Content string   `json:"content"`
-	Tags    []string `json:"tags"`
+	Title         string   `json:"title"`
+	Slug          string   `json:"slug"`
+	Content       string   `json:"content"`
+	ContentFormat string   `json:"content_format"`
+	Tags          []string `json:"tags"`
+	CoverMediaID  *uint    `json:"cover_media_id"`
+	RemoveCover   bool     `json:"remove_cover"`
+	Author        string   `json:"author"`
+	Status        string   `json:"status"`
 }
 
 type PostWithRelated struct {
@@ -48,34 +100,68 @@ type PostWithRelated struct {
 }
 
 func (s *PostService) CreatePost(ctx context.Context, in CreatePostInput) (*models.Post, error) {
-	post := &models.Post{Title: in.Title, Content: in.Content, Tags: pq.StringArray(in.Tags)}
+	post := &models.Post{Title: in.Title, Content: in.Content, ContentFormat: in.ContentFormat, Tags: pq.StringArray(in.Tags), CoverMediaID: in.CoverMediaID, Author: in.Author, Status: in.Status}
+	if err := renderContent(post); err != nil { return nil, err }
+	if err := s.checkCover(ctx, in.CoverMediaID); err != nil { return nil, err }
+	if post.Status == "" {
+		post.Status = models.PostPublished
+	}
+	if post.Status == models.PostPublished {
+		now := time.Now()
+		post.PublishedAt = &now
+	}
+	base, romanized := slugSource(in.Slug, in.Title)
 	var created *models.Post
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		if err := s.repo.Create(ctx, tx, post); err != nil { return err }
-		if err := s.repo.LogActivity(ctx, tx, "new_post", post.ID); err != nil { return err }
-		created = post
-		return nil
+	var logged []*models.ActivityLog
+	err := retrySlugConflicts(func() error {
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			post.ID, logged = 0, nil
+			sl, err := s.uniqueSlug(ctx, tx, base, 0)
+			if err != nil { return err }
+			post.Slug = sl
+			if err := s.repo.Create(ctx, tx, post); err != nil { return err }
+			if !romanized {
+				// nothing in the title to build a slug from: name it after its ID
+				sl, err := s.uniqueSlug(ctx, tx, idSlug(post.ID), post.ID)
+				if err != nil { return err }
+				post.Slug = sl
+				if err := s.repo.Update(ctx, tx, post); err != nil { return err }
+			}
+			if err := s.linkCover(ctx, tx, post); err != nil { return err }
+			if err := s.record(ctx, tx, &logged, "new_post", post, postChanges(nil, post)); err != nil { return err }
+			if post.Status == models.PostPublished {
+				if err := s.record(ctx, tx, &logged, "publish_post", post, postChanges(nil, post, "status", "published_at")); err != nil { return err }
+			}
+			created = post
+			return nil
+		})
 	})
 	if err != nil { return nil, err }
-	_ = s.es.IndexPost(ctx, created.ID, map[string]interface{}{ 
-		"id": created.ID, 
-		"title": created.Title, 
-		"content": created.Content,
-		"tags": created.Tags,
-	})
+	s.events.Publish(ctx, logged...)
+	s.metrics.PostCreated()
+	// the ID may have been looked up (and cached as missing) before it existed
+	s.marks.mark(ctx, fmt.Sprintf("post:%d", created.ID))
+	_ = s.cache.Del(ctx, fmt.Sprintf("post:%d", created.ID))
+	_ = s.es.IndexPost(ctx, created.ID, esDoc(created))
+	s.invalidateFeeds(ctx, created)
+	s.bumpSearchGenerations(ctx, created)
 	return created, nil
 }
 
+// GetPost is a cache-aside read guarded against stampedes on hot keys; see cache.Cache.Fetch.
+// Misses read from a replica unless the post was written moments ago.
 func (s *PostService) GetPost(ctx context.Context, id uint) (*models.Post, error) {
 	key := fmt.Sprintf("post:%d", id)
 	var post models.Post
-	if found, err := s.cache.GetJSON(ctx, key, &post); err == nil && found {
-		return &post, nil
-	}
-	p, err := s.repo.GetByID(ctx, id)
+	err := s.cache.Fetch(ctx, key, &post, func(ctx context.Context) (interface{}, error) {
+		p, err := s.repo.GetByID(s.marks.reader(ctx, key), id)
+		// remembered briefly so probing for missing IDs doesn't reach Postgres
+		if errors.Is(err, gorm.ErrRecordNotFound) { return nil, cache.ErrNotFound }
+		return p, err
+	})
+	if errors.Is(err, cache.ErrNotFound) { return nil, ErrNotFound }
 	if err != nil { return nil, err }
-	_ = s.cache.SetJSON(ctx, key, p)
-	return p, nil
+	return &post, nil
 }
 
 func (s *PostService) GetPostWithRelated(ctx context.Context, id uint) (*PostWithRelated, error) {
@@ -90,6 +176,7 @@ func (s *PostService) GetPostWithRelated(ctx context.Context, id uint) (*PostWit
 		// Log error but don't fail the request
 		relatedPosts = []map[string]interface{}{}
 	}
+	s.attachDocCovers(ctx, relatedPosts)
 
 	return &PostWithRelated{
 		Post:         post,
@@ -97,23 +184,383 @@ func (s *PostService) GetPostWithRelated(ctx context.Context, id uint) (*PostWit
 	}, nil
 }
 
+// UpdatePost replaces the post's title, content and tags. Format, author,
+// status and cover are kept unless given, and RemoveCover clears the cover.
 func (s *PostService) UpdatePost(ctx context.Context, id uint, in UpdatePostInput) (*models.Post, error) {
-	post := &models.Post{ID: id, Title: in.Title, Content: in.Content, Tags: pq.StringArray(in.Tags)}
-	if err := s.repo.Update(ctx, post); err != nil { return nil, err }
+	if in.RemoveCover { in.CoverMediaID = nil }
+	post := &models.Post{ID: id, Title: in.Title, Content: in.Content, ContentFormat: in.ContentFormat, Tags: pq.StringArray(in.Tags), CoverMediaID: in.CoverMediaID, Author: in.Author, Status: in.Status}
+	if err := s.checkCover(ctx, in.CoverMediaID); err != nil { return nil, err }
+	var old *models.Post
+	var logged []*models.ActivityLog
+	err := retrySlugConflicts(func() error {
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			logged = nil
+			cur, err := s.repo.GetForUpdate(ctx, tx, id)
+			if err != nil { return err }
+			old = cur
+			// the stored format is kept unless given, so a markdown post isn't
+			// re-rendered as plain text
+			if in.ContentFormat == "" {
+				post.ContentFormat = cur.ContentFormat
+			}
+			if err := renderContent(post); err != nil { return err }
+			post.Slug = cur.Slug
+			// author, status and cover are kept unless given explicitly
+			if post.Author == "" {
+				post.Author = cur.Author
+			}
+			if post.CoverMediaID == nil && !in.RemoveCover {
+				post.CoverMediaID = cur.CoverMediaID
+			}
+			if post.Status == "" {
+				post.Status = cur.Status
+			}
+			post.PublishedAt = cur.PublishedAt
+			if post.Status == models.PostPublished && cur.PublishedAt == nil {
+				now := time.Now()
+				post.PublishedAt = &now
+			}
+			if in.Slug != "" || in.Title != cur.Title || cur.Slug == "" {
+				base, romanized := slugSource(in.Slug, in.Title)
+				if !romanized {
+					base = idSlug(id)
+				}
+				sl, err := s.uniqueSlug(ctx, tx, base, id)
+				if err != nil { return err }
+				if sl != cur.Slug {
+					if err := s.repo.MoveSlug(ctx, tx, id, cur.Slug, sl); err != nil { return err }
+					post.Slug = sl
+				}
+			}
+			if err := s.linkCover(ctx, tx, post); err != nil { return err }
+			if err := s.repo.Update(ctx, tx, post); err != nil { return err }
+			if post.Status == models.PostPublished && cur.Status != models.PostPublished {
+				if err := s.record(ctx, tx, &logged, "publish_post", post, postChanges(cur, post, "status", "published_at")); err != nil { return err }
+			}
+			return s.record(ctx, tx, &logged, "update_post", post, postChanges(cur, post))
+		})
+	})
+	if err != nil { return nil, notFound(err) }
+	s.events.Publish(ctx, logged...)
+	s.metrics.PostUpdated()
+	s.marks.mark(ctx, fmt.Sprintf("post:%d", id))
 	_ = s.cache.Del(ctx, fmt.Sprintf("post:%d", id))
-	_ = s.es.IndexPost(ctx, id, map[string]interface{}{ 
-		"id": id, 
-		"title": post.Title, 
-		"content": post.Content,
-		"tags": post.Tags,
+	_ = s.es.IndexPost(ctx, id, esDoc(post))
+	s.invalidateFeeds(ctx, old, post)
+	s.bumpSearchGenerations(ctx, old, post)
+	// a replica may not have the update yet
+	return s.repo.GetByID(db.WithPrimary(ctx), id)
+}
+
+// DeletePost removes the post with its slug history and media links, then
+// garbage-collects media that no other post references.
+func (s *PostService) DeletePost(ctx context.Context, id uint) error {
+	var slugs []string
+	var mediaIDs []uint
+	var old *models.Post
+	var logged []*models.ActivityLog
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if old, err = s.repo.GetForUpdate(ctx, tx, id); err != nil { return err }
+		if slugs, err = s.repo.Slugs(ctx, tx, id); err != nil { return err }
+		if mediaIDs, err = s.mediaRepo.UnlinkPost(ctx, tx, id); err != nil { return err }
+		if err := s.repo.Delete(ctx, tx, id); err != nil { return err }
+		return s.record(ctx, tx, &logged, "delete_post", old, postChanges(old, nil))
 	})
-	return s.repo.GetByID(ctx, id)
+	if err != nil { return notFound(err) }
+	s.events.Publish(ctx, logged...)
+	s.metrics.PostDeleted()
+
+	keys := []string{fmt.Sprintf("post:%d", id)}
+	for _, sl := range slugs {
+		keys = append(keys, "post:slug:"+sl)
+	}
+	s.marks.mark(ctx, keys[0])
+	_ = s.cache.Del(ctx, keys...)
+	_ = s.es.DeletePost(ctx, id)
+	s.invalidateFeeds(ctx, old)
+	s.bumpSearchGenerations(ctx, old)
+	s.media.CollectGarbage(ctx, mediaIDs)
+	return nil
 }
 
-func (s *PostService) SearchByTag(ctx context.Context, tag string) ([]models.Post, error) {
-	return s.repo.SearchByTag(ctx, tag)
+// record logs action in the activity log, attributed to the actor in ctx,
+// and queues the matching webhook event, both inside tx. The entry is
+// appended to logged so it can be published once tx commits.
+func (s *PostService) record(ctx context.Context, tx *gorm.DB, logged *[]*models.ActivityLog, action string, p *models.Post, changes models.Changes) error {
+	actor := activity.ActorFrom(ctx)
+	entry := &models.ActivityLog{
+		Action:    action,
+		PostID:    p.ID,
+		ActorID:   actor.ID,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		RequestID: actor.RequestID,
+		Changes:   changes,
+	}
+	if err := s.repo.LogActivity(ctx, tx, entry); err != nil { return err }
+	*logged = append(*logged, entry)
+	return enqueueEvent(ctx, tx, s.hooks, action, p)
 }
 
+// invalidateFeeds drops every cached feed the given post versions appear in.
+// Drafts never appear in a feed, so only published versions count.
+func (s *PostService) invalidateFeeds(ctx context.Context, versions ...*models.Post) {
+	var keys []string
+	for _, p := range versions {
+		if p != nil && p.Status == models.PostPublished {
+			keys = append(keys, feed.AffectedKeys(p.Tags, p.Author)...)
+		}
+	}
+	if len(keys) > 0 {
+		s.marks.mark(ctx, keys...)
+		_ = s.cache.Del(ctx, keys...)
+	}
+}
+
+// bumpSearchGenerations retires cached search results the given post
+// versions may appear in: full-text results for every write, tag results
+// for each of their tags.
+func (s *PostService) bumpSearchGenerations(ctx context.Context, versions ...*models.Post) {
+	keys := []string{searchGenKey}
+	seen := map[string]bool{}
+	for _, p := range versions {
+		if p == nil { continue }
+		for _, t := range p.Tags {
+			if !seen[t] {
+				seen[t] = true
+				keys = append(keys, tagGenKey(t))
+			}
+		}
+	}
+	// full-text results come from Elasticsearch, not a replica
+	s.marks.mark(ctx, keys[1:]...)
+	_ = s.cache.BumpGenerations(ctx, keys...)
+}
+
+// GetPostBySlug resolves a current or historical slug. Callers should compare
+// the returned post's Slug with the requested one to detect a moved post.
+func (s *PostService) GetPostBySlug(ctx context.Context, sl string) (*models.Post, error) {
+	id, err := s.resolveSlug(ctx, sl)
+	if err != nil { return nil, err }
+	return s.GetPost(ctx, id)
+}
+
+// resolveSlug caches slug->id. Slugs are never handed to another post once used,
+// so entries stay valid after a rename and need no invalidation.
+func (s *PostService) resolveSlug(ctx context.Context, sl string) (uint, error) {
+	key := "post:slug:" + sl
+	var id uint
+	if found, err := s.cache.Get(ctx, key, &id); err == nil && found {
+		return id, nil
+	}
+	id, err := s.repo.GetIDBySlug(ctx, sl)
+	if err != nil { return 0, err }
+	_ = s.cache.Set(ctx, key, id, 0)
+	return id, nil
+}
+
+func (s *PostService) uniqueSlug(ctx context.Context, tx *gorm.DB, base string, postID uint) (string, error) {
+	for n := 1; n <= maxSlugAttempts; n++ {
+		candidate := slug.WithSuffix(base, n)
+		taken, err := s.repo.SlugTaken(ctx, tx, candidate, postID)
+		if err != nil { return "", err }
+		if !taken { return candidate, nil }
+	}
+	return "", errors.New("could not allocate a unique slug")
+}
+
+// renderContent fills ContentHTML and the derived listing fields from Content.
+// The source is kept as-is so editors get back exactly what they wrote.
+func renderContent(p *models.Post) error {
+	if p.ContentFormat == "" {
+		p.ContentFormat = markup.FormatPlain
+	}
+	html, err := markup.Render(p.ContentFormat, p.Content)
+	if err != nil { return err }
+	p.ContentHTML = html
+
+	sum := markup.Summarize(html, excerptLength)
+	p.Excerpt = sum.Excerpt
+	p.WordCount = sum.WordCount
+	p.ReadingTimeMinutes = sum.ReadingTimeMinutes
+	p.TOC = make(models.TOC, 0, len(sum.Headings))
+	for _, h := range sum.Headings {
+		p.TOC = append(p.TOC, models.TOCEntry{Level: h.Level, ID: h.ID, Text: h.Text})
+	}
+	return nil
+}
+
+// Reindex writes every post to the search index, batch posts at a time, and
+// returns how many it indexed. progress, if set, is called after each batch
+// with the running total.
+func (s *PostService) Reindex(ctx context.Context, batch int, progress func(indexed int)) (int, error) {
+	var indexed int
+	var after uint
+	for {
+		posts, err := s.repo.ListAfter(ctx, after, batch)
+		if err != nil { return indexed, err }
+		for i := range posts {
+			if err := s.es.IndexPost(ctx, posts[i].ID, esDoc(&posts[i])); err != nil {
+				return indexed, fmt.Errorf("index post %d: %w", posts[i].ID, err)
+			}
+			indexed++
+		}
+		if len(posts) > 0 && progress != nil { progress(indexed) }
+		if len(posts) < batch { return indexed, nil }
+		after = posts[len(posts)-1].ID
+	}
+}
+
+// esDoc is the search document for p. Only plain text is indexed as content
+// so markup doesn't pollute matches.
+func esDoc(p *models.Post) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   p.ID,
+		"title":                p.Title,
+		"slug":                 p.Slug,
+		"content":              markup.PlainText(p.ContentHTML),
+		"tags":                 p.Tags,
+		"author":               p.Author,
+		"status":               p.Status,
+		"published_at":         p.PublishedAt,
+		"cover_media_id":       p.CoverMediaID,
+		"excerpt":              p.Excerpt,
+		"word_count":           p.WordCount,
+		"reading_time_minutes": p.ReadingTimeMinutes,
+		"toc":                  p.TOC,
+	}
+}
+
+// notFound maps gorm's missing-row error to ErrNotFound so handlers don't depend on gorm.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// slugSource returns the slug base for a post and whether it came from the
+// explicit slug or the title; when neither has anything to romanize it
+// returns slug.Fallback and false.
+func slugSource(explicit, title string) (string, bool) {
+	if sl, ok := slug.Try(explicit); ok {
+		return sl, true
+	}
+	if sl, ok := slug.Try(title); ok {
+		return sl, true
+	}
+	return slug.Fallback, false
+}
+
+// idSlug is the slug of a post whose title can't be romanized, e.g. "post-42".
+func idSlug(id uint) string {
+	return fmt.Sprintf("%s-%d", slug.Fallback, id)
+}
+
+// retrySlugConflicts reruns a write transaction that lost a race for its
+// slug, so it picks the next free one.
+func retrySlugConflicts(fn func() error) error {
+	var err error
+	for i := 0; i < slugRetries; i++ {
+		if err = fn(); !errors.Is(err, repository.ErrSlugConflict) { return err }
+	}
+	return err
+}
+
+// SearchByTag results are cached per tag generation and, like GetPost, filled
+// from a replica unless the tag was written moments ago. Covers are attached
+// after the cache so thumbnails show up as soon as processing finishes.
+func (s *PostService) SearchByTag(ctx context.Context, tag string) ([]models.PostSummary, error) {
+	tag = strings.TrimSpace(tag)
+	load := func(ctx context.Context) (interface{}, error) { return s.repo.SearchByTag(s.marks.reader(ctx, tagGenKey(tag)), tag) }
+	var posts []models.PostSummary
+	if err := s.cachedSearch(ctx, tagGenKey(tag), "search:tag:"+url.QueryEscape(tag), &posts, load); err != nil { return nil, err }
+	s.metrics.Searched("tag", len(posts))
+	s.attachCovers(ctx, posts)
+	return posts, nil
+}
+
+// SearchES results are cached under the normalized query and the global
+// search generation.
 func (s *PostService) SearchES(ctx context.Context, q string) ([]map[string]interface{}, error) {
-	return s.es.SearchPosts(ctx, q)
+	q = normalizeQuery(q)
+	load := func(ctx context.Context) (interface{}, error) { return s.es.SearchPosts(ctx, q) }
+	sum := sha256.Sum256([]byte(q))
+	var docs []map[string]interface{}
+	if err := s.cachedSearch(ctx, searchGenKey, "search:q:"+hex.EncodeToString(sum[:16]), &docs, load); err != nil { return nil, err }
+	s.metrics.Searched("fulltext", len(docs))
+	s.attachDocCovers(ctx, docs)
+	return docs, nil
+}
+
+const searchGenKey = "gen:search"
+
+func tagGenKey(tag string) string { return "gen:tag:" + url.QueryEscape(tag) }
+
+// cachedSearch reads base under the current value of genKey. If the
+// generation can't be read the cache is bypassed rather than risking
+// results from before a write.
+func (s *PostService) cachedSearch(ctx context.Context, genKey, base string, dest interface{}, load cache.Loader) error {
+	gens, err := s.cache.Generations(ctx, genKey)
+	if err != nil {
+		v, err := load(ctx)
+		if err != nil { return err }
+		b, err := json.Marshal(v)
+		if err != nil { return err }
+		return json.Unmarshal(b, dest)
+	}
+	return s.cache.Fetch(ctx, fmt.Sprintf("%s:g%d", base, gens[0]), dest, load)
+}
+
+// normalizeQuery makes equivalent queries share a cache key: the analyzer
+// ignores case and extra whitespace anyway.
+func normalizeQuery(q string) string {
+	return strings.ToLower(strings.Join(strings.Fields(q), " "))
+}
+
+func (s *PostService) checkCover(ctx context.Context, id *uint) error {
+	if id == nil { return nil }
+	if _, err := s.mediaRepo.GetByID(ctx, *id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) { return ErrInvalidCoverMedia }
+		return err
+	}
+	return nil
+}
+
+// linkCover makes the cover a regular attachment of the post, so media GC
+// treats it like any other referenced file.
+func (s *PostService) linkCover(ctx context.Context, tx *gorm.DB, p *models.Post) error {
+	if p.CoverMediaID == nil { return nil }
+	return s.mediaRepo.Link(ctx, tx, p.ID, *p.CoverMediaID)
+}
+
+// attachCovers fills in thumbnails for listings. Covers are decoration, so
+// a lookup failure leaves them empty rather than failing the listing.
+func (s *PostService) attachCovers(ctx context.Context, posts []models.PostSummary) {
+	var ids []uint
+	for _, p := range posts {
+		if p.CoverMediaID != nil { ids = append(ids, *p.CoverMediaID) }
+	}
+	if len(ids) == 0 { return }
+	covers, err := s.media.Covers(ctx, ids)
+	if err != nil { return }
+	for i := range posts {
+		if posts[i].CoverMediaID != nil { posts[i].Cover = covers[*posts[i].CoverMediaID] }
+	}
+}
+
+func (s *PostService) attachDocCovers(ctx context.Context, docs []map[string]interface{}) {
+	var ids []uint
+	for _, d := range docs {
+		if id, ok := d["cover_media_id"].(float64); ok { ids = append(ids, uint(id)) }
+	}
+	if len(ids) == 0 { return }
+	covers, err := s.media.Covers(ctx, ids)
+	if err != nil { return }
+	for _, d := range docs {
+		if id, ok := d["cover_media_id"].(float64); ok {
+			if c := covers[uint(id)]; c != nil { d["cover"] = c }
+		}
+	}
 }
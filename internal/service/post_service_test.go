@@ -0,0 +1,812 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/fakes"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/service"
+	"github.com/example/blog-service/internal/storage"
+)
+
+var (
+	_ service.Transactor    = fakes.Tx{}
+	_ service.PostStore     = (*fakes.Posts)(nil)
+	_ service.FeedStore     = (*fakes.Posts)(nil)
+	_ service.SitemapStore  = (*fakes.Posts)(nil)
+	_ service.ActivityStore = (*fakes.Posts)(nil)
+	_ service.MediaStore    = (*fakes.Media)(nil)
+	_ service.WebhookStore  = (*fakes.Webhooks)(nil)
+	_ service.SearchIndex   = (*fakes.Search)(nil)
+)
+
+var errBoom = errors.New("boom")
+
+type env struct {
+	svc    *service.PostService
+	cache  *cache.Memory
+	posts  *fakes.Posts
+	media  *fakes.Media
+	search *fakes.Search
+	hooks  *fakes.Webhooks
+	deps   service.PostDeps
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	e := &env{
+		cache:  cache.NewMemory(&config.Config{CacheTTLSec: 60, CacheNegativeSec: 30, CacheMemoryMaxEntries: 1000}),
+		posts:  fakes.NewPosts(),
+		media:  fakes.NewMedia(),
+		search: fakes.NewSearch(),
+		hooks:  fakes.NewWebhooks(),
+	}
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	library := service.NewMediaService(&config.Config{}, service.MediaDeps{DB: fakes.Tx{}, Media: e.media, Posts: e.posts, Store: store})
+	e.deps = service.PostDeps{
+		DB:       fakes.Tx{},
+		Cache:    e.cache,
+		Search:   e.search,
+		Posts:    e.posts,
+		Media:    e.media,
+		Webhooks: e.hooks,
+		Library:  library,
+		Events:   service.NewActivityStream(e.cache),
+	}
+	e.svc = service.NewPostService(e.deps)
+	return e
+}
+
+func (e *env) create(t *testing.T, in service.CreatePostInput) *models.Post {
+	t.Helper()
+	p, err := e.svc.CreatePost(context.Background(), in)
+	if err != nil {
+		t.Fatalf("CreatePost(%q): %v", in.Title, err)
+	}
+	return p
+}
+
+func (e *env) actions() []string {
+	var out []string
+	for _, a := range e.posts.Activity() {
+		out = append(out, a.Action)
+	}
+	return out
+}
+
+func docIDs(docs []map[string]interface{}) []uint {
+	var ids []uint
+	for _, d := range docs {
+		ids = append(ids, uint(d["id"].(float64)))
+	}
+	return ids
+}
+
+func TestCreatePost(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        service.CreatePostInput
+		withCover bool
+		coverID   uint
+		setup     func(e *env)
+		wantErr   error
+		check     func(t *testing.T, e *env, p *models.Post)
+	}{
+		{
+			name: "published by default",
+			in:   service.CreatePostInput{Title: "Hello World", Content: "first post"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != "hello-world" || p.Status != models.PostPublished || p.PublishedAt == nil {
+					t.Errorf("got slug %q status %q published_at %v", p.Slug, p.Status, p.PublishedAt)
+				}
+				if got := e.actions(); !reflect.DeepEqual(got, []string{"new_post", "publish_post"}) {
+					t.Errorf("activity = %v", got)
+				}
+				if got := e.hooks.Events(); !reflect.DeepEqual(got, []string{models.EventPostCreated, models.EventPostPublished}) {
+					t.Errorf("webhook events = %v", got)
+				}
+				if d := e.search.Doc(p.ID); d == nil || d["slug"] != "hello-world" {
+					t.Errorf("indexed doc = %v", d)
+				}
+			},
+		},
+		{
+			name: "draft is not published",
+			in:   service.CreatePostInput{Title: "Later", Content: "wip", Status: models.PostDraft},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Status != models.PostDraft || p.PublishedAt != nil {
+					t.Errorf("got status %q published_at %v", p.Status, p.PublishedAt)
+				}
+				if got := e.hooks.Events(); !reflect.DeepEqual(got, []string{models.EventPostCreated}) {
+					t.Errorf("webhook events = %v", got)
+				}
+			},
+		},
+		{
+			name: "explicit slug",
+			in:   service.CreatePostInput{Title: "Hello", Slug: "Custom Slug!", Content: "x"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != "custom-slug" {
+					t.Errorf("slug = %q", p.Slug)
+				}
+			},
+		},
+		{
+			name: "taken slug gets a suffix",
+			in:   service.CreatePostInput{Title: "Hello World", Content: "again"},
+			setup: func(e *env) {
+				_, _ = e.svc.CreatePost(context.Background(), service.CreatePostInput{Title: "Hello World", Content: "x"})
+			},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != "hello-world-2" {
+					t.Errorf("slug = %q", p.Slug)
+				}
+			},
+		},
+		{
+			name: "cyrillic title is romanized",
+			in:   service.CreatePostInput{Title: "Привет, мир", Content: "x"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != "privet-mir" {
+					t.Errorf("slug = %q", p.Slug)
+				}
+			},
+		},
+		{
+			name: "unromanizable title gets an ID slug",
+			in:   service.CreatePostInput{Title: "你好世界", Content: "x"},
+			setup: func(e *env) {
+				_, _ = e.svc.CreatePost(context.Background(), service.CreatePostInput{Title: "こんにちは", Content: "x"})
+			},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != fmt.Sprintf("post-%d", p.ID) {
+					t.Errorf("slug = %q for post %d", p.Slug, p.ID)
+				}
+				if got, err := e.svc.GetPostBySlug(context.Background(), p.Slug); err != nil || got.ID != p.ID {
+					t.Errorf("GetPostBySlug = %+v, %v", got, err)
+				}
+			},
+		},
+		{
+			name: "markdown is rendered",
+			in:   service.CreatePostInput{Title: "Doc", Content: "# Intro\n\nSome words here", ContentFormat: "markdown"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if !strings.Contains(p.ContentHTML, "<h1") || len(p.TOC) != 1 || p.WordCount == 0 {
+					t.Errorf("html %q toc %v words %d", p.ContentHTML, p.TOC, p.WordCount)
+				}
+				if d := e.search.Doc(p.ID); strings.Contains(d["content"].(string), "<") {
+					t.Errorf("indexed content has markup: %q", d["content"])
+				}
+			},
+		},
+		{
+			name:      "cover is linked",
+			in:        service.CreatePostInput{Title: "Pic", Content: "x"},
+			withCover: true,
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if !e.media.Linked(p.ID, *p.CoverMediaID) {
+					t.Errorf("cover %d not linked", *p.CoverMediaID)
+				}
+			},
+		},
+		{
+			name:    "unknown cover",
+			in:      service.CreatePostInput{Title: "Pic", Content: "x"},
+			coverID: 99,
+			wantErr: service.ErrInvalidCoverMedia,
+		},
+		{
+			name:  "index failure does not fail the write",
+			in:    service.CreatePostInput{Title: "Offline", Content: "x"},
+			setup: func(e *env) { e.search.FailOn("IndexPost", errBoom) },
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if _, err := e.svc.GetPost(context.Background(), p.ID); err != nil {
+					t.Errorf("GetPost: %v", err)
+				}
+				if d := e.search.Doc(p.ID); d != nil {
+					t.Errorf("unexpected doc %v", d)
+				}
+			},
+		},
+		{
+			name:    "repository failure",
+			in:      service.CreatePostInput{Title: "Broken", Content: "x"},
+			setup:   func(e *env) { e.posts.FailOn("Create", errBoom) },
+			wantErr: errBoom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+			in := tt.in
+			if tt.withCover {
+				id := e.media.Add(models.Media{Width: 10, Height: 10})
+				in.CoverMediaID = &id
+			}
+			if tt.coverID != 0 {
+				in.CoverMediaID = &tt.coverID
+			}
+			p, err := e.svc.CreatePost(context.Background(), in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, e, p)
+			}
+		})
+	}
+}
+
+// racyPosts says every slug is free once, like a concurrent create that
+// commits between the check and the insert.
+type racyPosts struct {
+	*fakes.Posts
+	raced bool
+}
+
+func (r *racyPosts) SlugTaken(ctx context.Context, tx *gorm.DB, slug string, postID uint) (bool, error) {
+	if !r.raced {
+		r.raced = true
+		return false, nil
+	}
+	return r.Posts.SlugTaken(ctx, tx, slug, postID)
+}
+
+func TestCreatePostRetriesSlugConflict(t *testing.T) {
+	e := newEnv(t)
+	e.create(t, service.CreatePostInput{Title: "Same Title", Content: "x"})
+	posts := &racyPosts{Posts: e.posts}
+	deps := e.deps
+	deps.Posts = posts
+	svc := service.NewPostService(deps)
+
+	p, err := svc.CreatePost(context.Background(), service.CreatePostInput{Title: "Same Title", Content: "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !posts.raced || p.Slug != "same-title-2" {
+		t.Errorf("slug = %q, want the retry to pick same-title-2", p.Slug)
+	}
+	if got := e.actions(); !reflect.DeepEqual(got, []string{"new_post", "publish_post", "new_post", "publish_post"}) {
+		t.Errorf("activity = %v, want one pair per post", got)
+	}
+}
+
+func TestGetPost(t *testing.T) {
+	tests := []struct {
+		name      string
+		exists    bool
+		failLoad  error
+		reads     int
+		wantErr   error
+		wantLoads int
+		wantStats cache.Stats
+	}{
+		{name: "miss then hits", exists: true, reads: 3, wantLoads: 1, wantStats: cache.Stats{Misses: 1, Hits: 2}},
+		{name: "missing post is cached negatively", reads: 2, wantErr: service.ErrNotFound, wantLoads: 1, wantStats: cache.Stats{Misses: 1, NegHits: 1}},
+		{name: "load errors are not cached", failLoad: errBoom, reads: 2, wantErr: errBoom, wantLoads: 2, wantStats: cache.Stats{Misses: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			id := uint(42)
+			if tt.exists {
+				id = e.create(t, service.CreatePostInput{Title: "Cached", Content: "x"}).ID
+			}
+			e.posts.FailOn("GetByID", tt.failLoad)
+			for i := 0; i < tt.reads; i++ {
+				p, err := e.svc.GetPost(context.Background(), id)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("read %d: err = %v, want %v", i, err, tt.wantErr)
+				}
+				if err == nil && (p.ID != id || p.Title != "Cached") {
+					t.Fatalf("read %d: got %+v", i, p)
+				}
+			}
+			if got := e.posts.Calls("GetByID"); got != tt.wantLoads {
+				t.Errorf("loads = %d, want %d", got, tt.wantLoads)
+			}
+			if got := e.cache.Stats(); !reflect.DeepEqual(got, tt.wantStats) {
+				t.Errorf("stats = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestGetPostCreatedAfterMiss(t *testing.T) {
+	e := newEnv(t)
+	if _, err := e.svc.GetPost(context.Background(), 1); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	p := e.create(t, service.CreatePostInput{Title: "Now here", Content: "x"})
+	if p.ID != 1 {
+		t.Fatalf("id = %d", p.ID)
+	}
+	if _, err := e.svc.GetPost(context.Background(), 1); err != nil {
+		t.Fatalf("cached miss outlived create: %v", err)
+	}
+}
+
+// readLog records whether each cache fill read from the primary.
+type readLog struct {
+	*fakes.Posts
+	primary []bool
+}
+
+func (r *readLog) GetByID(ctx context.Context, id uint) (*models.Post, error) {
+	r.primary = append(r.primary, db.ReadsPrimary(ctx))
+	return r.Posts.GetByID(ctx, id)
+}
+
+func (r *readLog) SearchByTag(ctx context.Context, tag string) ([]models.PostSummary, error) {
+	r.primary = append(r.primary, db.ReadsPrimary(ctx))
+	return r.Posts.SearchByTag(ctx, tag)
+}
+
+func TestCacheFillsAfterWrite(t *testing.T) {
+	e := newEnv(t)
+	posts := &readLog{Posts: e.posts}
+	deps := e.deps
+	deps.Posts = posts
+	deps.ReplicaLag = time.Minute
+	svc := service.NewPostService(deps)
+	ctx := context.Background()
+
+	p, err := svc.CreatePost(ctx, service.CreatePostInput{Title: "Fresh", Content: "x", Tags: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetPost(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SearchByTag(ctx, "go"); err != nil {
+		t.Fatal(err)
+	}
+	// the marks expire, and so do the cached copies
+	if _, err := e.cache.Purge(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetPost(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SearchByTag(ctx, "go"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, true, false, false}; !reflect.DeepEqual(posts.primary, want) {
+		t.Errorf("primary reads = %v, want %v", posts.primary, want)
+	}
+}
+
+func TestUpdatePost(t *testing.T) {
+	tests := []struct {
+		name    string
+		create  *service.CreatePostInput
+		update  service.UpdatePostInput
+		setup   func(e *env, id uint)
+		wantErr error
+		check   func(t *testing.T, e *env, p *models.Post)
+	}{
+		{
+			name:   "retitle moves the slug",
+			create: &service.CreatePostInput{Title: "First Title", Content: "x"},
+			update: service.UpdatePostInput{Title: "Second Title", Content: "x"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != "second-title" {
+					t.Errorf("slug = %q", p.Slug)
+				}
+				old, err := e.svc.GetPostBySlug(context.Background(), "first-title")
+				if err != nil || old.ID != p.ID || old.Slug != "second-title" {
+					t.Errorf("old slug resolved to %+v, %v", old, err)
+				}
+			},
+		},
+		{
+			name:   "same title keeps the slug",
+			create: &service.CreatePostInput{Title: "Stable", Content: "x"},
+			update: service.UpdatePostInput{Title: "Stable", Content: "edited"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Slug != "stable" || p.Content != "edited" {
+					t.Errorf("got slug %q content %q", p.Slug, p.Content)
+				}
+				if n := e.posts.Calls("MoveSlug"); n != 0 {
+					t.Errorf("MoveSlug called %d times", n)
+				}
+			},
+		},
+		{
+			name:   "author and status are kept when omitted",
+			create: &service.CreatePostInput{Title: "Mine", Content: "x", Author: "ann", Status: models.PostDraft},
+			update: service.UpdatePostInput{Title: "Mine", Content: "y"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Author != "ann" || p.Status != models.PostDraft || p.PublishedAt != nil {
+					t.Errorf("got author %q status %q published_at %v", p.Author, p.Status, p.PublishedAt)
+				}
+			},
+		},
+		{
+			name:   "content format is kept when omitted",
+			create: &service.CreatePostInput{Title: "Doc", Content: "# Intro", ContentFormat: "markdown"},
+			update: service.UpdatePostInput{Title: "Doc", Content: "# Intro\n\nMore *words*"},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.ContentFormat != "markdown" || !strings.Contains(p.ContentHTML, "<em>words</em>") || len(p.TOC) != 1 {
+					t.Errorf("got format %q html %q toc %v", p.ContentFormat, p.ContentHTML, p.TOC)
+				}
+			},
+		},
+		{
+			name:   "publishing a draft",
+			create: &service.CreatePostInput{Title: "Soon", Content: "x", Status: models.PostDraft},
+			update: service.UpdatePostInput{Title: "Soon", Content: "x", Status: models.PostPublished},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.PublishedAt == nil {
+					t.Error("published_at not set")
+				}
+				if got, want := e.actions(), []string{"new_post", "publish_post", "update_post"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("activity = %v, want %v", got, want)
+				}
+				want := []string{models.EventPostCreated, models.EventPostPublished, models.EventPostUpdated}
+				if got := e.hooks.Events(); !reflect.DeepEqual(got, want) {
+					t.Errorf("webhook events = %v, want %v", got, want)
+				}
+			},
+		},
+		{
+			name:   "cached post is invalidated",
+			create: &service.CreatePostInput{Title: "Before", Content: "x"},
+			update: service.UpdatePostInput{Title: "After", Content: "x"},
+			setup: func(e *env, id uint) {
+				_, _ = e.svc.GetPost(context.Background(), id)
+			},
+			check: func(t *testing.T, e *env, p *models.Post) {
+				got, err := e.svc.GetPost(context.Background(), p.ID)
+				if err != nil || got.Title != "After" {
+					t.Errorf("GetPost = %+v, %v", got, err)
+				}
+				if d := e.search.Doc(p.ID); d["title"] != "After" {
+					t.Errorf("indexed title = %v", d["title"])
+				}
+			},
+		},
+		{
+			name:   "index failure does not fail the write",
+			create: &service.CreatePostInput{Title: "Before", Content: "x"},
+			update: service.UpdatePostInput{Title: "After", Content: "x"},
+			setup:  func(e *env, id uint) { e.search.FailOn("IndexPost", errBoom) },
+			check: func(t *testing.T, e *env, p *models.Post) {
+				if p.Title != "After" {
+					t.Errorf("title = %q", p.Title)
+				}
+			},
+		},
+		{
+			name:    "unknown post",
+			update:  service.UpdatePostInput{Title: "Nope", Content: "x"},
+			wantErr: service.ErrNotFound,
+		},
+		{
+			name:    "unknown cover",
+			create:  &service.CreatePostInput{Title: "Pic", Content: "x"},
+			update:  service.UpdatePostInput{Title: "Pic", Content: "x", CoverMediaID: new(uint)},
+			wantErr: service.ErrInvalidCoverMedia,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			id := uint(42)
+			if tt.create != nil {
+				id = e.create(t, *tt.create).ID
+			}
+			if tt.setup != nil {
+				tt.setup(e, id)
+			}
+			p, err := e.svc.UpdatePost(context.Background(), id, tt.update)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, e, p)
+			}
+		})
+	}
+}
+
+func TestUpdatePostCover(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	cover := e.media.Add(models.Media{})
+	p := e.create(t, service.CreatePostInput{Title: "Pic", Content: "x", CoverMediaID: &cover})
+
+	p, err := e.svc.UpdatePost(ctx, p.ID, service.UpdatePostInput{Title: "Pic", Content: "edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CoverMediaID == nil || *p.CoverMediaID != cover {
+		t.Errorf("cover = %v after an edit that didn't mention it, want %d", p.CoverMediaID, cover)
+	}
+
+	other := e.media.Add(models.Media{})
+	if p, err = e.svc.UpdatePost(ctx, p.ID, service.UpdatePostInput{Title: "Pic", Content: "x", CoverMediaID: &other}); err != nil {
+		t.Fatal(err)
+	}
+	if p.CoverMediaID == nil || *p.CoverMediaID != other {
+		t.Errorf("cover = %v, want %d", p.CoverMediaID, other)
+	}
+
+	if p, err = e.svc.UpdatePost(ctx, p.ID, service.UpdatePostInput{Title: "Pic", Content: "x", RemoveCover: true}); err != nil {
+		t.Fatal(err)
+	}
+	if p.CoverMediaID != nil {
+		t.Errorf("cover = %d, want it removed", *p.CoverMediaID)
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	e := newEnv(t)
+	cover := e.media.Add(models.Media{})
+	p := e.create(t, service.CreatePostInput{Title: "Gone Soon", Content: "x", CoverMediaID: &cover})
+	if _, err := e.svc.UpdatePost(context.Background(), p.ID, service.UpdatePostInput{Title: "Gone", Content: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.svc.GetPostBySlug(context.Background(), "gone-soon"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.svc.DeletePost(context.Background(), p.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if _, err := e.svc.GetPost(context.Background(), p.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("GetPost after delete: %v", err)
+	}
+	if _, err := e.svc.GetPostBySlug(context.Background(), "gone-soon"); err == nil {
+		t.Error("old slug still resolves")
+	}
+	if e.search.Doc(p.ID) != nil {
+		t.Error("doc still indexed")
+	}
+	if _, err := e.media.GetByID(context.Background(), cover); err == nil {
+		t.Error("orphaned cover not collected")
+	}
+	if err := e.svc.DeletePost(context.Background(), p.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	e := newEnv(t)
+	var ids []uint
+	for _, title := range []string{"One", "Two", "Three"} {
+		ids = append(ids, e.create(t, service.CreatePostInput{Title: title, Content: "x"}).ID)
+	}
+	for _, id := range ids {
+		_ = e.search.DeletePost(context.Background(), id)
+	}
+
+	var progress []int
+	n, err := e.svc.Reindex(context.Background(), 2, func(indexed int) { progress = append(progress, indexed) })
+	if err != nil || n != 3 {
+		t.Fatalf("Reindex = %d, %v; want 3", n, err)
+	}
+	if !reflect.DeepEqual(progress, []int{2, 3}) {
+		t.Errorf("progress = %v, want [2 3]", progress)
+	}
+	for _, id := range ids {
+		if e.search.Doc(id) == nil {
+			t.Errorf("post %d not indexed", id)
+		}
+	}
+
+	e.search.FailOn("IndexPost", errBoom)
+	if n, err := e.svc.Reindex(context.Background(), 2, nil); !errors.Is(err, errBoom) || n != 0 {
+		t.Errorf("Reindex with failing index = %d, %v", n, err)
+	}
+}
+
+func TestSearchByTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		fail    error
+		want    []uint
+		wantErr error
+	}{
+		{name: "containment", tag: "go", want: []uint{3, 1}},
+		{name: "single post", tag: "db", want: []uint{1}},
+		{name: "surrounding space is ignored", tag: "  rust ", want: []uint{2}},
+		{name: "no match", tag: "java"},
+		{name: "tags are case-sensitive", tag: "Go"},
+		{name: "drafts are left out", tag: "wip"},
+		{name: "repository failure", tag: "go", fail: errBoom, wantErr: errBoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			e.create(t, service.CreatePostInput{Title: "A", Content: "x", Tags: []string{"go", "db"}})
+			e.create(t, service.CreatePostInput{Title: "B", Content: "x", Tags: []string{"rust"}})
+			e.create(t, service.CreatePostInput{Title: "C", Content: "x", Tags: []string{"go"}})
+			e.create(t, service.CreatePostInput{Title: "D", Content: "x", Tags: []string{"go", "wip"}, Status: models.PostDraft})
+			e.posts.FailOn("SearchByTag", tt.fail)
+
+			got, err := e.svc.SearchByTag(context.Background(), tt.tag)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			var ids []uint
+			for _, p := range got {
+				ids = append(ids, p.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ids = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchByTagCache(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	e.create(t, service.CreatePostInput{Title: "A", Content: "x", Tags: []string{"go"}})
+
+	steps := []struct {
+		name      string
+		write     *service.CreatePostInput
+		wantLoads int
+		wantIDs   []uint
+	}{
+		{name: "first read loads", wantLoads: 1, wantIDs: []uint{1}},
+		{name: "second read is cached", wantLoads: 1, wantIDs: []uint{1}},
+		{name: "unrelated tag keeps the cache", write: &service.CreatePostInput{Title: "B", Content: "x", Tags: []string{"rust"}}, wantLoads: 1, wantIDs: []uint{1}},
+		{name: "matching tag retires it", write: &service.CreatePostInput{Title: "C", Content: "x", Tags: []string{"go"}}, wantLoads: 2, wantIDs: []uint{3, 1}},
+	}
+	for _, s := range steps {
+		if s.write != nil {
+			e.create(t, *s.write)
+		}
+		got, err := e.svc.SearchByTag(ctx, "go")
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		var ids []uint
+		for _, p := range got {
+			ids = append(ids, p.ID)
+		}
+		if n := e.posts.Calls("SearchByTag"); n != s.wantLoads || !reflect.DeepEqual(ids, s.wantIDs) {
+			t.Errorf("%s: loads %d ids %v, want %d %v", s.name, n, ids, s.wantLoads, s.wantIDs)
+		}
+	}
+}
+
+func TestSearchByTagCovers(t *testing.T) {
+	e := newEnv(t)
+	ready := e.media.Add(models.Media{Width: 640, Height: 480})
+	pending := e.media.Add(models.Media{Status: models.MediaPending})
+	e.create(t, service.CreatePostInput{Title: "A", Content: "x", Tags: []string{"pics"}, CoverMediaID: &ready})
+	e.create(t, service.CreatePostInput{Title: "B", Content: "x", Tags: []string{"pics"}, CoverMediaID: &pending})
+
+	got, err := e.svc.SearchByTag(context.Background(), "pics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Cover != nil || got[1].Cover == nil || got[1].Cover.Width != 640 {
+		t.Errorf("covers = %+v", got)
+	}
+}
+
+func TestSearchES(t *testing.T) {
+	tests := []struct {
+		name      string
+		queries   []string
+		fail      error
+		want      []uint
+		wantErr   error
+		wantLoads int
+	}{
+		{name: "title match", queries: []string{"postgres"}, want: []uint{1}, wantLoads: 1},
+		{name: "content match", queries: []string{"goroutines"}, want: []uint{2}, wantLoads: 1},
+		{name: "more terms rank first", queries: []string{"tuning goroutines"}, want: []uint{2, 1}, wantLoads: 1},
+		{name: "no match", queries: []string{"kubernetes"}, wantLoads: 1},
+		{name: "equivalent queries share the cache", queries: []string{"Tuning", "  tuning ", "TUNING"}, want: []uint{1, 2}, wantLoads: 1},
+		{name: "search failure", queries: []string{"postgres", "postgres"}, fail: errBoom, wantErr: errBoom, wantLoads: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			e.create(t, service.CreatePostInput{Title: "Tuning Postgres", Content: "vacuum and indexes"})
+			e.create(t, service.CreatePostInput{Title: "Go tuning", Content: "Goroutines everywhere"})
+			e.create(t, service.CreatePostInput{Title: "Tuning drafts", Content: "postgres goroutines", Status: models.PostDraft})
+			e.search.FailOn("SearchPosts", tt.fail)
+
+			for _, q := range tt.queries {
+				docs, err := e.svc.SearchES(context.Background(), q)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("%q: err = %v, want %v", q, err, tt.wantErr)
+				}
+				if got := docIDs(docs); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%q: ids = %v, want %v", q, got, tt.want)
+				}
+				for _, d := range docs {
+					if _, ok := d["content"]; ok {
+						t.Errorf("%q: result carries content", q)
+					}
+				}
+			}
+			if n := e.search.Calls("SearchPosts"); n != tt.wantLoads {
+				t.Errorf("loads = %d, want %d", n, tt.wantLoads)
+			}
+		})
+	}
+}
+
+func TestSearchESRecoversAfterFailure(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	e.create(t, service.CreatePostInput{Title: "Hello", Content: "x"})
+
+	e.search.FailOn("SearchPosts", errBoom)
+	if _, err := e.svc.SearchES(ctx, "hello"); !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v", err)
+	}
+	e.search.FailOn("SearchPosts", nil)
+	docs, err := e.svc.SearchES(ctx, "hello")
+	if err != nil || !reflect.DeepEqual(docIDs(docs), []uint{1}) {
+		t.Fatalf("after recovery: %v, %v", docIDs(docs), err)
+	}
+
+	// a write retires cached results
+	e.create(t, service.CreatePostInput{Title: "Hello again", Content: "x"})
+	docs, err = e.svc.SearchES(ctx, "hello")
+	if err != nil || !reflect.DeepEqual(docIDs(docs), []uint{1, 2}) {
+		t.Fatalf("after write: %v, %v", docIDs(docs), err)
+	}
+}
+
+func TestGetPostWithRelated(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    error
+		want    []uint
+		wantErr error
+	}{
+		{name: "shared tags rank first", want: []uint{3, 2}},
+		{name: "search failure leaves related empty", fail: errBoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			p := e.create(t, service.CreatePostInput{Title: "Main", Content: "x", Tags: []string{"go", "db"}})
+			e.create(t, service.CreatePostInput{Title: "One", Content: "x", Tags: []string{"go"}})
+			e.create(t, service.CreatePostInput{Title: "Both", Content: "x", Tags: []string{"db", "go"}})
+			e.create(t, service.CreatePostInput{Title: "None", Content: "x", Tags: []string{"rust"}})
+			e.create(t, service.CreatePostInput{Title: "Draft", Content: "x", Tags: []string{"db", "go"}, Status: models.PostDraft})
+			e.search.FailOn("FindRelatedPosts", tt.fail)
+
+			got, err := e.svc.GetPostWithRelated(context.Background(), p.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got.RelatedPosts == nil {
+				t.Fatal("related posts is nil")
+			}
+			if ids := docIDs(got.RelatedPosts); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("related = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
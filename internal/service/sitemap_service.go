@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/repository"
+	"github.com/example/blog-service/internal/sitemap"
+)
+
+type SitemapService struct {
+	cache   cache.Cache
+	repo    SitemapStore
+	baseURL string
+}
+
+func NewSitemapService(cfg *config.Config, repo SitemapStore, cache cache.Cache) *SitemapService {
+	return &SitemapService{
+		cache:   cache,
+		repo:    repo,
+		baseURL: cfg.PublicBaseURL,
+	}
+}
+
+// cachedSitemap remembers which activity log high-water mark it was built at.
+type cachedSitemap struct {
+	Generation uint   `json:"generation"`
+	Body       []byte `json:"body"`
+}
+
+// Index returns the sitemap index pointing at every chunk of every kind.
+func (s *SitemapService) Index(ctx context.Context) ([]byte, error) {
+	return s.cached(ctx, "sitemap:index", func(buf *bytes.Buffer) error {
+		var entries []sitemap.IndexEntry
+		for _, kind := range repository.SitemapKinds() {
+			chunks, err := s.repo.Chunks(ctx, kind, sitemap.MaxURLs)
+			if err != nil {
+				return err
+			}
+			for _, c := range chunks {
+				entries = append(entries, sitemap.IndexEntry{
+					Loc:     fmt.Sprintf("%s/sitemaps/%s-%d.xml", s.baseURL, kind, c.Index+1),
+					LastMod: c.LastMod,
+				})
+			}
+		}
+		return sitemap.WriteIndex(buf, entries)
+	})
+}
+
+// Chunk returns the n-th (1-based) sitemap of kind.
+func (s *SitemapService) Chunk(ctx context.Context, kind string, n int) ([]byte, error) {
+	loc, ok := s.locators()[kind]
+	if !ok || n < 1 {
+		return nil, ErrNotFound
+	}
+	return s.cached(ctx, fmt.Sprintf("sitemap:%s:%d", kind, n), func(buf *bytes.Buffer) error {
+		w := sitemap.NewURLSetWriter(buf)
+		count := 0
+		err := s.repo.Each(ctx, kind, n-1, sitemap.MaxURLs, func(e repository.SitemapEntry) error {
+			count++
+			return w.Add(loc(e.Key), e.LastMod)
+		})
+		if err != nil {
+			return err
+		}
+		if count == 0 && n > 1 {
+			return ErrNotFound
+		}
+		return w.Close()
+	})
+}
+
+func (s *SitemapService) locators() map[string]func(string) string {
+	return map[string]func(string) string{
+		"posts": func(k string) string { return s.baseURL + "/posts/by-slug/" + url.PathEscape(k) },
+		// tags and authors have feeds rather than pages
+		"tags":    func(k string) string { return s.baseURL + "/tags/" + url.PathEscape(k) + "/feed.rss" },
+		"authors": func(k string) string { return s.baseURL + "/authors/" + url.PathEscape(k) + "/feed.rss" },
+	}
+}
+
+// cached serves key from Redis unless the activity log has moved on since it
+// was built, in which case build regenerates it.
+func (s *SitemapService) cached(ctx context.Context, key string, build func(*bytes.Buffer) error) ([]byte, error) {
+	gen, err := s.repo.LatestActivityID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var c cachedSitemap
+	if found, err := s.cache.Get(ctx, key, &c); err == nil && found && c.Generation == gen {
+		return c.Body, nil
+	}
+	var buf bytes.Buffer
+	if err := build(&buf); err != nil {
+		return nil, err
+	}
+	_ = s.cache.Set(ctx, key, cachedSitemap{Generation: gen, Body: buf.Bytes()}, 0)
+	return buf.Bytes(), nil
+}
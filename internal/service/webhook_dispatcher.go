@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/repository"
+)
+
+const (
+	// a claim older than this is assumed to belong to a crashed worker
+	webhookStaleAfter  = 5 * time.Minute
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// how much of a response body is kept in the delivery log
+	webhookMaxLoggedBody = 2048
+)
+
+// WebhookDispatcher is the background worker that sends queued webhook
+// deliveries. Like MediaProcessor it claims work from the database, so every
+// replica can run it.
+type WebhookDispatcher struct {
+	repo        *repository.WebhookRepository
+	client      *http.Client
+	concurrency int
+	interval    time.Duration
+	maxAttempts int
+}
+
+func NewWebhookDispatcher(cfg *config.Config, database *db.Database) *WebhookDispatcher {
+	interval := time.Duration(cfg.WebhookPollSec) * time.Second
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return &WebhookDispatcher{
+		repo:        repository.NewWebhookRepository(database.Gorm),
+		client:      &http.Client{Timeout: time.Duration(cfg.WebhookTimeoutSec) * time.Second},
+		concurrency: cfg.WebhookWorkers,
+		interval:    interval,
+		maxAttempts: cfg.WebhookMaxAttempts,
+	}
+}
+
+// Run polls for due deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *WebhookDispatcher) loop(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		for {
+			worked, err := d.deliverNext(ctx)
+			if err != nil {
+				slog.Error("webhook dispatcher", "error", err)
+			}
+			if !worked || ctx.Err() != nil {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliverNext(ctx context.Context) (bool, error) {
+	dl, err := d.repo.ClaimNext(ctx, webhookStaleAfter)
+	if err != nil || dl == nil {
+		return false, err
+	}
+
+	start := time.Now()
+	code, body, sendErr := d.send(ctx, dl)
+	dl.DurationMs = time.Since(start).Milliseconds()
+	dl.ResponseCode, dl.ResponseBody, dl.Error = code, body, ""
+
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		dl.Status, dl.DeliveredAt = models.DeliverySucceeded, &now
+	case dl.Attempts >= d.maxAttempts:
+		dl.Status, dl.Error = models.DeliveryFailed, sendErr.Error()
+	default:
+		dl.Status, dl.Error = models.DeliveryPending, sendErr.Error()
+		dl.NextAttemptAt = time.Now().Add(webhookBackoff(dl.Attempts))
+	}
+	if err := d.repo.SaveAttempt(ctx, dl); err != nil {
+		return true, err
+	}
+	if sendErr != nil {
+		return true, fmt.Errorf("delivery %d to webhook %d (attempt %d): %w", dl.ID, dl.WebhookID, dl.Attempts, sendErr)
+	}
+	return true, nil
+}
+
+// send posts the payload and treats anything but a 2xx as a failure.
+func (d *WebhookDispatcher) send(ctx context.Context, dl *models.WebhookDelivery) (int, string, error) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.Webhook.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blog-service-webhooks/1")
+	req.Header.Set("X-Webhook-Event", dl.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(dl.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(dl.Webhook.Secret, ts, dl.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxLoggedBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(body), fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// SignWebhook is the hex HMAC-SHA256 of "<timestamp>.<body>" under secret.
+// Binding the timestamp lets receivers reject replayed requests.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff doubles the wait after every failed attempt, up to webhookMaxBackoff.
+func webhookBackoff(attempts int) time.Duration {
+	wait := webhookBaseBackoff
+	for i := 1; i < attempts && wait < webhookMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > webhookMaxBackoff {
+		wait = webhookMaxBackoff
+	}
+	return wait
+}
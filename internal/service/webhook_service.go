@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/blog-service/internal/models"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// WebhookEvents lists the events a webhook may subscribe to.
+var WebhookEvents = []string{models.EventPostCreated, models.EventPostUpdated, models.EventPostPublished, models.EventPostDeleted}
+
+// activityEvents maps activity log actions to the webhook event they emit.
+var activityEvents = map[string]string{
+	"new_post":     models.EventPostCreated,
+	"update_post":  models.EventPostUpdated,
+	"publish_post": models.EventPostPublished,
+	"delete_post":  models.EventPostDeleted,
+}
+
+const maxDeliveryPage = 100
+
+type WebhookService struct {
+	repo WebhookStore
+}
+
+func NewWebhookService(repo WebhookStore) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+type WebhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+// CreatedWebhook is returned once, on creation; it is the only time the
+// secret leaves the service.
+type CreatedWebhook struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+func (s *WebhookService) Create(ctx context.Context, in WebhookInput) (*CreatedWebhook, error) {
+	if err := validateWebhook(in); err != nil {
+		return nil, err
+	}
+	secret := in.Secret
+	if secret == "" {
+		var err error
+		if secret, err = randomSecret(); err != nil {
+			return nil, err
+		}
+	}
+	w := &models.Webhook{URL: in.URL, Events: in.Events, Secret: secret, Active: in.Active == nil || *in.Active}
+	if err := s.repo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	return &CreatedWebhook{Webhook: w, Secret: secret}, nil
+}
+
+func (s *WebhookService) Get(ctx context.Context, id uint) (*models.Webhook, error) {
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return w, nil
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]models.Webhook, error) {
+	return s.repo.List(ctx)
+}
+
+// Update replaces the subscription. An empty secret keeps the current one.
+func (s *WebhookService) Update(ctx context.Context, id uint, in WebhookInput) (*models.Webhook, error) {
+	if err := validateWebhook(in); err != nil {
+		return nil, err
+	}
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	w.URL, w.Events = in.URL, in.Events
+	if in.Secret != "" {
+		w.Secret = in.Secret
+	}
+	if in.Active != nil {
+		w.Active = *in.Active
+	}
+	if err := s.repo.Update(ctx, w); err != nil {
+		return nil, notFound(err)
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id uint) error {
+	return notFound(s.repo.Delete(ctx, id))
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID uint, status string, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxDeliveryPage {
+		limit = maxDeliveryPage
+	}
+	return s.repo.ListDeliveries(ctx, webhookID, status, limit)
+}
+
+func (s *WebhookService) Delivery(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error) {
+	d, err := s.repo.GetDelivery(ctx, webhookID, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return d, nil
+}
+
+// Redeliver queues the payload of an earlier delivery again, whatever its outcome.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, id uint) (*models.WebhookDelivery, error) {
+	d, err := s.Delivery(ctx, webhookID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Redeliver(ctx, d)
+}
+
+func validateWebhook(in WebhookInput) error {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+	if len(in.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	for _, e := range in.Events {
+		if _, ok := eventAction(e); !ok {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+	return nil
+}
+
+func eventAction(event string) (string, bool) {
+	for action, e := range activityEvents {
+		if e == event {
+			return action, true
+		}
+	}
+	return "", false
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// webhookPost is the post as seen by webhook consumers.
+type webhookPost struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Slug        string     `json:"slug"`
+	Tags        []string   `json:"tags"`
+	Author      string     `json:"author"`
+	Status      string     `json:"status"`
+	PublishedAt *time.Time `json:"published_at"`
+	Excerpt     string     `json:"excerpt"`
+}
+
+type webhookPayload struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Post       webhookPost `json:"post"`
+}
+
+// enqueueEvent queues the webhook event for an activity log action, if it has one.
+func enqueueEvent(ctx context.Context, tx *gorm.DB, hooks WebhookQueue, action string, p *models.Post) error {
+	event, ok := activityEvents[action]
+	if !ok {
+		return nil
+	}
+	payload, err := json.Marshal(webhookPayload{
+		Event:      event,
+		OccurredAt: time.Now().UTC(),
+		Post: webhookPost{
+			ID:          p.ID,
+			Title:       p.Title,
+			Slug:        p.Slug,
+			Tags:        p.Tags,
+			Author:      p.Author,
+			Status:      p.Status,
+			PublishedAt: p.PublishedAt,
+			Excerpt:     p.Excerpt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return hooks.Enqueue(ctx, tx, event, payload)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/db"
+)
+
+// writeMarks sends the cache fills of keys a write just invalidated to the
+// primary for DB_REPLICA_LAG_SECONDS. A replica that hasn't replayed the
+// write yet would otherwise refill the key with the old rows for its whole
+// TTL. Once the mark expires, fills read from the replicas again.
+type writeMarks struct {
+	cache  cache.Cache
+	window time.Duration
+}
+
+func newWriteMarks(cfg *config.Config, c cache.Cache) writeMarks {
+	return writeMarks{cache: c, window: replicaLag(cfg)}
+}
+
+// replicaLag is the window of writeMarks, 0 (no marks) without replicas.
+func replicaLag(cfg *config.Config) time.Duration {
+	if len(cfg.DBReplicaDSNs) == 0 {
+		return 0
+	}
+	return time.Duration(cfg.DBReplicaLagSec) * time.Second
+}
+
+func writeMarkKey(key string) string { return "written:" + key }
+
+// mark must run before keys are invalidated, so that no fill after the
+// invalidation misses the mark.
+func (m writeMarks) mark(ctx context.Context, keys ...string) {
+	if m.window <= 0 {
+		return
+	}
+	for _, key := range keys {
+		_ = m.cache.Set(ctx, writeMarkKey(key), true, m.window)
+	}
+}
+
+// reader returns the ctx to fill key with: one that reads from the primary
+// while key is marked, or when the mark can't be checked.
+func (m writeMarks) reader(ctx context.Context, key string) context.Context {
+	if m.window <= 0 {
+		return ctx
+	}
+	var marked bool
+	if found, err := m.cache.Get(ctx, writeMarkKey(key), &marked); err != nil || found {
+		return db.WithPrimary(ctx)
+	}
+	return ctx
+}
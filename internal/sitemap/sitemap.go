@@ -0,0 +1,73 @@
+package sitemap
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// MaxURLs is the protocol limit of URLs per sitemap file.
+const MaxURLs = 50000
+
+const (
+	header = xml.Header
+	ns     = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type IndexEntry struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// WriteIndex writes a <sitemapindex> referencing the given sitemaps.
+func WriteIndex(w io.Writer, entries []IndexEntry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(header)
+	bw.WriteString(`<sitemapindex xmlns="` + ns + `">` + "\n")
+	for _, e := range entries {
+		bw.WriteString("  <sitemap><loc>")
+		xml.EscapeText(bw, []byte(e.Loc))
+		bw.WriteString("</loc>")
+		writeLastMod(bw, e.LastMod)
+		bw.WriteString("</sitemap>\n")
+	}
+	bw.WriteString("</sitemapindex>\n")
+	return bw.Flush()
+}
+
+// URLSetWriter writes a <urlset> one URL at a time, so callers can feed it
+// straight from a database cursor.
+type URLSetWriter struct {
+	bw *bufio.Writer
+}
+
+func NewURLSetWriter(w io.Writer) *URLSetWriter {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(header)
+	bw.WriteString(`<urlset xmlns="` + ns + `">` + "\n")
+	return &URLSetWriter{bw: bw}
+}
+
+func (u *URLSetWriter) Add(loc string, lastMod time.Time) error {
+	u.bw.WriteString("  <url><loc>")
+	if err := xml.EscapeText(u.bw, []byte(loc)); err != nil {
+		return err
+	}
+	u.bw.WriteString("</loc>")
+	writeLastMod(u.bw, lastMod)
+	_, err := u.bw.WriteString("</url>\n")
+	return err
+}
+
+func (u *URLSetWriter) Close() error {
+	u.bw.WriteString("</urlset>\n")
+	return u.bw.Flush()
+}
+
+func writeLastMod(bw *bufio.Writer, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	bw.WriteString("<lastmod>" + t.UTC().Format(time.RFC3339) + "</lastmod>")
+}
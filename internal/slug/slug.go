@@ -0,0 +1,111 @@
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MaxLength = 100
+	Fallback  = "post"
+)
+
+// letters that don't decompose into ASCII base + combining mark under NFD
+var translit = map[rune]string{
+	'đ': "d", 'Đ': "d",
+	'ß': "ss",
+	'æ': "ae", 'Æ': "ae",
+	'ø': "o", 'Ø': "o",
+	'œ': "oe", 'Œ': "oe",
+	'ł': "l", 'Ł': "l",
+	'þ': "th", 'Þ': "th",
+	'ð': "d", 'Ð': "d",
+	'ı': "i",
+	'&': "and",
+}
+
+// Cyrillic and Greek, romanized letter by letter after lowercasing; accents
+// on Greek vowels are already gone by then
+var scripts = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'є': "ye", 'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y", 'к': "k",
+	'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'ў': "u",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// Make turns an arbitrary title into a lowercase, hyphen-separated ASCII slug,
+// or Fallback when nothing in it can be romanized.
+func Make(s string) string {
+	if out, ok := Try(s); ok {
+		return out
+	}
+	return Fallback
+}
+
+// Try is Make without the fallback: it reports false when s has no letters
+// or digits it can romanize, e.g. a title in Chinese or Arabic.
+func Try(s string) (string, bool) {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if out, _, err := transform.String(t, s); err == nil {
+		s = out
+	}
+
+	var b strings.Builder
+	dash := false
+	write := func(part string) {
+		for _, r := range part {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+				b.WriteRune(r)
+				dash = false
+			case r >= 'A' && r <= 'Z':
+				b.WriteRune(unicode.ToLower(r))
+				dash = false
+			default:
+				if !dash && b.Len() > 0 {
+					b.WriteByte('-')
+					dash = true
+				}
+			}
+		}
+	}
+	for _, r := range s {
+		if rep, ok := translit[r]; ok {
+			write(rep)
+			continue
+		}
+		if rep, ok := scripts[unicode.ToLower(r)]; ok {
+			write(rep)
+			continue
+		}
+		write(string(r))
+	}
+
+	out := strings.Trim(b.String(), "-")
+	if len(out) > MaxLength {
+		out = strings.TrimRight(out[:MaxLength], "-")
+	}
+	return out, out != ""
+}
+
+// WithSuffix returns the n-th collision candidate for base, e.g. "hello-world-2".
+func WithSuffix(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+	suffix := "-" + strconv.Itoa(n)
+	if len(base)+len(suffix) > MaxLength {
+		base = strings.TrimRight(base[:MaxLength-len(suffix)], "-")
+	}
+	return base + suffix
+}
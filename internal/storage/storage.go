@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/example/blog-service/internal/config"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage is a flat key/value blob store for uploaded media.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func New(ctx context.Context, cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return NewLocal(cfg.MediaDir)
+	case "s3":
+		return NewS3(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
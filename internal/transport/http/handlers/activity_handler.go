@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/service"
+)
+
+type ActivityHandler struct {
+	service *service.ActivityService
+	stream  *service.ActivityStream
+}
+
+func NewActivityHandler(activity *service.ActivityService, stream *service.ActivityStream) *ActivityHandler {
+	return &ActivityHandler{service: activity, stream: stream}
+}
+
+type activityReq struct {
+	PostID  uint      `form:"post_id" binding:"omitempty,min=1"`
+	ActorID string    `form:"actor_id" binding:"omitempty,max=100"`
+	Action  string    `form:"action" binding:"omitempty,max=50"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor  string    `form:"cursor"`
+	Limit   int       `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// List serves GET /activity?post_id=&actor_id=&action=&from=&to=&cursor=&limit=.
+// from/to are RFC 3339; from is inclusive, to exclusive.
+func (h *ActivityHandler) List(c *gin.Context) {
+	var req activityReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := h.service.List(c.Request.Context(), service.ActivityQuery{
+		PostID:  req.PostID,
+		ActorID: req.ActorID,
+		Action:  req.Action,
+		From:    req.From,
+		To:      req.To,
+		Cursor:  req.Cursor,
+		Limit:   req.Limit,
+	})
+	if errors.Is(err, service.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if page.NextCursor != "" {
+		next := c.Request.URL.Query()
+		next.Set("cursor", page.NextCursor)
+		c.Header("Link", `<`+c.Request.URL.Path+"?"+next.Encode()+`>; rel="next"`)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+const activityHeartbeat = 15 * time.Second
+
+// Stream serves GET /activity/stream as Server-Sent Events. Each event's id
+// can be sent back as Last-Event-ID (header, or last_event_id for clients
+// that can't set headers) to resume after a disconnect.
+func (h *ActivityHandler) Stream(c *gin.Context) {
+	var f service.ActivityStreamFilter
+	if v := c.Query("post_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post_id"})
+			return
+		}
+		f.PostID = uint(id)
+	}
+	for _, v := range c.QueryArray("action") {
+		for _, a := range strings.Split(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				f.Actions = append(f.Actions, a)
+			}
+		}
+	}
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+
+	events, err := h.stream.Follow(c.Request.Context(), lastID, f)
+	if errors.Is(err, service.ErrInvalidEventID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the server's WriteTimeout would cut the stream off; lift it for this response
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// stop nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(activityHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev.Entry)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Entry.Action, data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/cache"
+)
+
+// CacheStats serves the cache counters since process start.
+func CacheStats(rc cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, rc.Stats())
+	}
+}
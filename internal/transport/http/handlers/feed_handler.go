@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/feed"
+	"github.com/example/blog-service/internal/service"
+)
+
+type FeedHandler struct {
+	service *service.FeedService
+}
+
+func NewFeedHandler(feeds *service.FeedService) *FeedHandler {
+	return &FeedHandler{service: feeds}
+}
+
+// Serve returns a handler for one feed format. The scope comes from the
+// optional :tag or :author route params.
+func (h *FeedHandler) Serve(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := feed.Scope{Tag: c.Param("tag"), Author: c.Param("author")}
+		rendered, err := h.service.Feed(c.Request.Context(), format, scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("ETag", rendered.ETag)
+		c.Header("Last-Modified", rendered.LastModified.UTC().Format(http.TimeFormat))
+		c.Header("Cache-Control", "public, max-age=300")
+		if notModified(c.Request, rendered) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, rendered.ContentType, rendered.Body)
+	}
+}
+
+// notModified implements RFC 9110 precedence: If-None-Match wins over If-Modified-Since.
+func notModified(r *http.Request, rendered *feed.Rendered) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == rendered.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !rendered.LastModified.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
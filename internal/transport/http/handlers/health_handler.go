@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/health"
+)
+
+// Healthz reports that the process is up and serving; it checks nothing
+// else, so a dependency outage doesn't get the process restarted.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz runs the dependency checks. It answers 503 when any fails or the
+// process is draining for shutdown.
+func Readyz(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rep := checker.Ready(c.Request.Context())
+		status := http.StatusOK
+		if !rep.OK() {
+			status = http.StatusServiceUnavailable
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(status, rep)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/service"
+)
+
+type MediaHandler struct {
+	service *service.MediaService
+}
+
+func NewMediaHandler(media *service.MediaService) *MediaHandler {
+	return &MediaHandler{service: media}
+}
+
+func (h *MediaHandler) Upload(c *gin.Context) {
+	// leave headroom for the multipart envelope and other form fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.service.MaxBytes()+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.ErrMediaTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	var postID uint
+	if v := c.PostForm("post_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post_id"})
+			return
+		}
+		postID = uint(id)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	media, err := h.service.Upload(c.Request.Context(), fh.Filename, f, postID)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, media)
+}
+
+func (h *MediaHandler) Get(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	media, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+// Serve streams the metadata-free original. Media is content-addressed and
+// never changes under an ID, so it can be cached forever and revalidated by
+// hash.
+func (h *MediaHandler) Serve(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	rd, rc, err := h.service.Open(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Content-Type", rd.MimeType)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", `"`+rd.Hash+`"`)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, rd.Name, rd.CreatedAt, rc)
+}
+
+// ServeRendition serves e.g. /media/1/renditions/thumbnail.webp.
+func (h *MediaHandler) ServeRendition(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	file := c.Param("file")
+	ext := path.Ext(file)
+	name := strings.TrimSuffix(file, ext)
+	format := strings.TrimPrefix(ext, ".")
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	rd, rc, err := h.service.OpenRendition(c.Request.Context(), id, name, format)
+	if err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Content-Type", rd.MimeType)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", `"`+rd.Hash+`"`)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, file, rd.CreatedAt, rc)
+}
+
+func (h *MediaHandler) ListForPost(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	media, err := h.service.ListForPost(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+func (h *MediaHandler) Attach(c *gin.Context) {
+	postID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	mediaID, ok := parseID(c, "media_id")
+	if !ok {
+		return
+	}
+	if err := h.service.Attach(c.Request.Context(), postID, mediaID); err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MediaHandler) Detach(c *gin.Context) {
+	postID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	mediaID, ok := parseID(c, "media_id")
+	if !ok {
+		return
+	}
+	if err := h.service.Detach(c.Request.Context(), postID, mediaID); err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func mediaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrMediaTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrUnsupportedMimeType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, service.ErrMediaNotReady):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrMediaFailed):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func parseID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+		return 0, false
+	}
+	return uint(id), true
+}
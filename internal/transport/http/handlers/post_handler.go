@@ -1,14 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/example/blog-service/internal/cache"
-	"github.com/example/blog-service/internal/db"
-	"github.com/example/blog-service/internal/search"
 	"github.com/example/blog-service/internal/service"
 )
 
@@ -16,20 +15,31 @@ type PostHandler struct {
 	service *service.PostService
 }
 
-func NewPostHandler(database *db.Database, cache *cache.RedisClient, es *search.Elastic) *PostHandler {
-	return &PostHandler{service: service.NewPostService(database, cache, es)}
+func NewPostHandler(posts *service.PostService) *PostHandler {
+	return &PostHandler{service: posts}
 }
 
 type createReq struct {
-	Title   string   `json:"title" binding:"required,min=1"`
-	Content string   `json:"content" binding:"required,min=1"`
-	Tags    []string `json:"tags"`
+	Title         string   `json:"title" binding:"required,min=1"`
+	Slug          string   `json:"slug" binding:"omitempty,max=255"`
+	Content       string   `json:"content" binding:"required,min=1"`
+	ContentFormat string   `json:"content_format" binding:"omitempty,oneof=markdown html plain"`
+	Tags          []string `json:"tags"`
+	CoverMediaID  *uint    `json:"cover_media_id" binding:"omitempty,min=1"`
+	Author        string   `json:"author" binding:"omitempty,max=100"`
+	Status        string   `json:"status" binding:"omitempty,oneof=draft published"`
 }
 
 type updateReq struct {
-	Title   string   `json:"title" binding:"required,min=1"`
-	Content string   `json:"content" binding:"required,min=1"`
-	Tags    []string `json:"tags"`
+	Title         string   `json:"title" binding:"required,min=1"`
+	Slug          string   `json:"slug" binding:"omitempty,max=255"`
+	Content       string   `json:"content" binding:"required,min=1"`
+	ContentFormat string   `json:"content_format" binding:"omitempty,oneof=markdown html plain"`
+	Tags          []string `json:"tags"`
+	CoverMediaID  *uint    `json:"cover_media_id" binding:"omitempty,min=1"`
+	RemoveCover   bool     `json:"remove_cover" binding:"excluded_with=CoverMediaID"`
+	Author        string   `json:"author" binding:"omitempty,max=100"`
+	Status        string   `json:"status" binding:"omitempty,oneof=draft published"`
 }
 
 func (h *PostHandler) CreatePost(c *gin.Context) {
@@ -38,9 +48,9 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	post, err := h.service.CreatePost(c.Request.Context(), service.CreatePostInput{Title: req.Title, Content: req.Content, Tags: req.Tags})
+	post, err := h.service.CreatePost(c.Request.Context(), service.CreatePostInput{Title: req.Title, Slug: req.Slug, Content: req.Content, ContentFormat: req.ContentFormat, Tags: req.Tags, CoverMediaID: req.CoverMediaID, Author: req.Author, Status: req.Status})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(postErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, post)
@@ -73,6 +83,34 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 	}
 }
 
+func (h *PostHandler) GetPostBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+	post, err := h.service.GetPostBySlug(c.Request.Context(), slug)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if post.Slug != slug {
+		target := "/posts/by-slug/" + url.PathEscape(post.Slug)
+		if q := c.Request.URL.RawQuery; q != "" {
+			target += "?" + q
+		}
+		c.Redirect(http.StatusMovedPermanently, target)
+		return
+	}
+
+	if c.Query("include_related") == "true" {
+		postWithRelated, err := h.service.GetPostWithRelated(c.Request.Context(), post.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, postWithRelated)
+		return
+	}
+	c.JSON(http.StatusOK, post)
+}
+
 func (h *PostHandler) UpdatePost(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil || id <= 0 {
@@ -84,14 +122,26 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	post, err := h.service.UpdatePost(c.Request.Context(), uint(id), service.UpdatePostInput{Title: req.Title, Content: req.Content, Tags: req.Tags})
+	post, err := h.service.UpdatePost(c.Request.Context(), uint(id), service.UpdatePostInput{Title: req.Title, Slug: req.Slug, Content: req.Content, ContentFormat: req.ContentFormat, Tags: req.Tags, CoverMediaID: req.CoverMediaID, RemoveCover: req.RemoveCover, Author: req.Author, Status: req.Status})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(postErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, post)
 }
 
+func (h *PostHandler) DeletePost(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.DeletePost(c.Request.Context(), id); err != nil {
+		c.JSON(postErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (h *PostHandler) SearchByTag(c *gin.Context) {
 	tag := c.Query("tag")
 	if tag == "" {
@@ -119,3 +169,14 @@ func (h *PostHandler) Search(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, res)
 }
+
+func postErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidCoverMedia):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/service"
+)
+
+type SitemapHandler struct {
+	service *service.SitemapService
+}
+
+func NewSitemapHandler(sitemaps *service.SitemapService) *SitemapHandler {
+	return &SitemapHandler{service: sitemaps}
+}
+
+func (h *SitemapHandler) Index(c *gin.Context) {
+	body, err := h.service.Index(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// Chunk serves /sitemaps/<kind>-<n>.xml.
+func (h *SitemapHandler) Chunk(c *gin.Context) {
+	name, ok := strings.CutSuffix(c.Param("file"), ".xml")
+	i := strings.LastIndexByte(name, '-')
+	if !ok || i <= 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+		return
+	}
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+		return
+	}
+	body, err := h.service.Chunk(c.Request.Context(), name[:i], n)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/service"
+)
+
+type WebhookHandler struct {
+	service *service.WebhookService
+}
+
+func NewWebhookHandler(webhooks *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: webhooks}
+}
+
+type webhookReq struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1,dive,required"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	Active *bool    `json:"active"`
+}
+
+func (r webhookReq) input() service.WebhookInput {
+	return service.WebhookInput{URL: r.URL, Events: r.Events, Secret: r.Secret, Active: r.Active}
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var req webhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := h.service.Create(c.Request.Context(), req.input())
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, w)
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	hooks, err := h.service.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *WebhookHandler) Get(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	w, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	var req webhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := h.service.Update(c.Request.Context(), id, req.input())
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	out, err := h.service.Deliveries(c.Request.Context(), id, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *WebhookHandler) Delivery(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseID(c, "delivery_id")
+	if !ok {
+		return
+	}
+	d, err := h.service.Delivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseID(c, "delivery_id")
+	if !ok {
+		return
+	}
+	d, err := h.service.Redeliver(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, d)
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidWebhook):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
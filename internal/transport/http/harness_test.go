@@ -0,0 +1,471 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/fakes"
+	"github.com/example/blog-service/internal/health"
+	"github.com/example/blog-service/internal/metrics"
+	"github.com/example/blog-service/internal/search"
+	"github.com/example/blog-service/internal/service"
+	"github.com/example/blog-service/internal/storage"
+	transport "github.com/example/blog-service/internal/transport/http"
+)
+
+// TestMain fails the run if a route in router.go has no test request, unless
+// only some tests were selected with -run.
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	code := m.Run()
+	if f := flag.Lookup("test.run"); code == 0 && (f == nil || f.Value.String() == "") {
+		if missing := uncoveredRoutes(); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "routes without tests:\n  %s\n", strings.Join(missing, "\n  "))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// esRequest is one request the Elasticsearch stub received.
+type esRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+type esRule struct {
+	method, path, contains string
+	status                 int
+	body                   string
+}
+
+// esStub stands in for Elasticsearch. It records every request and answers
+// from canned rules; the latest matching rule wins. Without one, writes
+// succeed and searches find nothing.
+type esStub struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []esRequest
+	rules    []esRule
+}
+
+func newESStub(t *testing.T) *esStub {
+	s := &esStub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// respond answers requests to method and path whose body contains contains.
+func (s *esStub) respond(method, path, contains string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, esRule{method, path, contains, status, body})
+}
+
+// received returns the recorded requests to method and path.
+func (s *esStub) received(method, path string) []esRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []esRequest
+	for _, r := range s.requests {
+		if r.Method == method && r.Path == path {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *esStub) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, esRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: string(body)})
+	status, resp := s.answer(r.Method, r.URL.Path, string(body))
+	s.mu.Unlock()
+
+	// the client refuses to talk to anything that doesn't claim to be Elasticsearch
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, resp)
+}
+
+func (s *esStub) answer(method, path, body string) (int, string) {
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		r := s.rules[i]
+		if r.method == method && r.path == path && strings.Contains(body, r.contains) {
+			return r.status, r.body
+		}
+	}
+	switch {
+	case path == "/":
+		return http.StatusOK, `{"tagline":"You Know, for Search"}`
+	case strings.HasSuffix(path, "/_search"):
+		return http.StatusOK, esHits()
+	case strings.Contains(path, "/_doc/") && method == http.MethodDelete:
+		return http.StatusOK, `{"result":"deleted"}`
+	case strings.Contains(path, "/_doc/"):
+		return http.StatusCreated, `{"result":"created"}`
+	}
+	return http.StatusNotFound, `{"error":"no canned response"}`
+}
+
+// esHits is a search response with docs as the hits' sources.
+func esHits(docs ...map[string]interface{}) string {
+	hits := []map[string]interface{}{}
+	for i, d := range docs {
+		hits = append(hits, map[string]interface{}{"_id": fmt.Sprint(i + 1), "_source": d})
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"hits": map[string]interface{}{"total": map[string]interface{}{"value": len(docs)}, "hits": hits},
+	})
+	return string(b)
+}
+
+// harness is the real router over fakes: the Elasticsearch stub, the
+// in-memory cache instead of Redis, in-memory repositories instead of
+// Postgres and media storage in a temporary directory.
+type harness struct {
+	t       *testing.T
+	cfg     *config.Config
+	router  transport.Router
+	es      *esStub
+	cache   *cache.Memory
+	posts   *fakes.Posts
+	media   *fakes.Media
+	hooks   *fakes.Webhooks
+	admins  *fakes.Admins
+	store   storage.Storage
+	metrics *metrics.Metrics
+	health  *health.Checker
+	// pgPing stands in for pinging Postgres in readiness checks
+	pgPing func(ctx context.Context) error
+}
+
+// newHarness builds the router over fakes; configure adjusts the config
+// before anything is built from it.
+func newHarness(t *testing.T, configure ...func(*config.Config)) *harness {
+	t.Helper()
+	h := &harness{
+		t:       t,
+		es:      newESStub(t),
+		posts:   fakes.NewPosts(),
+		media:   fakes.NewMedia(),
+		hooks:   fakes.NewWebhooks(),
+		admins:  fakes.NewAdmins(),
+		metrics: metrics.New(),
+		pgPing:  func(context.Context) error { return nil },
+	}
+	h.cfg = &config.Config{
+		PublicBaseURL:         "http://blog.test",
+		SiteTitle:             "Test Blog",
+		FeedSize:              20,
+		ElasticAddr:           h.es.URL,
+		CacheTTLSec:           60,
+		CacheNegativeSec:      30,
+		CacheMemoryMaxEntries: 1000,
+		MediaMaxBytes:         4 << 10,
+		MediaAllowedTypes:     []string{"image/png", "image/jpeg"},
+		HealthCheckTimeoutMs:  200,
+	}
+	for _, c := range configure {
+		c(h.cfg)
+	}
+	es, err := search.NewElastic(h.cfg, h.metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.store = store
+	h.cache = cache.NewMemory(h.cfg)
+	h.metrics.RegisterCache(h.cache)
+	h.health = health.NewChecker(time.Duration(h.cfg.HealthCheckTimeoutMs)*time.Millisecond,
+		health.Check{Name: "postgres", Run: func(ctx context.Context) error { return h.pgPing(ctx) }},
+		health.Check{Name: "cache", Run: h.cache.Ping},
+		health.Check{Name: "elasticsearch", Run: es.Ping},
+	)
+
+	stream := service.NewActivityStream(h.cache)
+	media := service.NewMediaService(h.cfg, service.MediaDeps{DB: fakes.Tx{}, Media: h.media, Posts: h.posts, Store: store})
+	router, err := transport.NewRouter(h.cfg, transport.Services{
+		Cache: h.cache,
+		Posts: service.NewPostService(service.PostDeps{
+			DB:       fakes.Tx{},
+			Cache:    h.cache,
+			Search:   es,
+			Posts:    h.posts,
+			Media:    h.media,
+			Webhooks: h.hooks,
+			Library:  media,
+			Events:   stream,
+			Metrics:  h.metrics,
+		}),
+		Media:    media,
+		Feeds:    service.NewFeedService(h.cfg, h.posts, h.cache),
+		Sitemaps: service.NewSitemapService(h.cfg, h.posts, h.cache),
+		Webhooks: service.NewWebhookService(h.hooks),
+		Activity: service.NewActivityService(h.posts),
+		Stream:   stream,
+		Admins:   service.NewAdminService(h.admins),
+		Metrics:  h.metrics,
+		Health:   h.health,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = router
+	registerRoutes(h.router)
+	return h
+}
+
+// do sends a request through the router and counts it towards route
+// coverage. A body is sent as JSON.
+func (h *harness) do(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	markCovered(method, path)
+	return h.serve(method, path, body, header)
+}
+
+func (h *harness) serve(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// mustDo is for setup steps, which have to succeed. They don't count as
+// coverage of the route.
+func (h *harness) mustDo(method, path, body string, want int) *httptest.ResponseRecorder {
+	h.t.Helper()
+	rec := h.serve(method, path, body, nil)
+	if rec.Code != want {
+		h.t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, want, rec.Body)
+	}
+	return rec
+}
+
+// seed creates webhook 1 (subscribed to post.created and post.updated), the
+// published post 1 "Hello World" by ann tagged go and db, and the draft
+// post 2 tagged go.
+// metric returns the value of the counter, gauge or histogram sample count
+// called name whose labels include labels (name=value pairs), summed over
+// the matching series.
+func (h *harness) metric(name string, labels ...string) float64 {
+	h.t.Helper()
+	families, err := h.metrics.Registry.Gather()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	var sum float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	series:
+		for _, m := range f.GetMetric() {
+			have := map[string]string{}
+			for _, l := range m.GetLabel() {
+				have[l.GetName()] = l.GetValue()
+			}
+			for i := 0; i+1 < len(labels); i += 2 {
+				if have[labels[i]] != labels[i+1] {
+					continue series
+				}
+			}
+			switch {
+			case m.Counter != nil:
+				sum += m.Counter.GetValue()
+			case m.Gauge != nil:
+				sum += m.Gauge.GetValue()
+			case m.Histogram != nil:
+				sum += float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return sum
+}
+
+func (h *harness) seed() {
+	h.t.Helper()
+	h.mustDo("POST", "/webhooks", `{"url":"https://hooks.test/in","events":["post.created","post.updated"]}`, http.StatusCreated)
+	h.mustDo("POST", "/posts", `{"title":"Hello World","content":"first post","tags":["go","db"],"author":"ann"}`, http.StatusCreated)
+	h.mustDo("POST", "/posts", `{"title":"Secret Draft","content":"wip","tags":["go"],"status":"draft"}`, http.StatusCreated)
+}
+
+// upload posts a multipart form to /media.
+func (h *harness) upload(name string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	markCovered("POST", "/media")
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	if data != nil {
+		fw, _ := w.CreateFormFile("file", name)
+		_, _ = fw.Write(data)
+	}
+	_ = w.Close()
+	req := httptest.NewRequest("POST", "/media", &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// routeCase is one request against a fresh, seeded harness.
+type routeCase struct {
+	name       string
+	setup      func(h *harness)
+	method     string
+	path       string
+	body       string
+	header     map[string]string
+	wantStatus int
+	wantBody   []string
+	notBody    []string
+	check      func(t *testing.T, h *harness, rec *httptest.ResponseRecorder)
+}
+
+func runRoutes(t *testing.T, cases []routeCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.seed()
+			if tc.setup != nil {
+				tc.setup(h)
+			}
+			rec := h.do(tc.method, tc.path, tc.body, tc.header)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			for _, s := range tc.wantBody {
+				if !strings.Contains(rec.Body.String(), s) {
+					t.Errorf("body lacks %q: %s", s, rec.Body)
+				}
+			}
+			for _, s := range tc.notBody {
+				if strings.Contains(rec.Body.String(), s) {
+					t.Errorf("body has %q: %s", s, rec.Body)
+				}
+			}
+			if tc.check != nil {
+				tc.check(t, h, rec)
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+// route coverage: every registered route must be hit by some request.
+var coverage = struct {
+	sync.Mutex
+	routes  map[string]bool
+	covered map[string]bool
+}{routes: map[string]bool{}, covered: map[string]bool{}}
+
+func registerRoutes(r transport.Router) {
+	coverage.Lock()
+	defer coverage.Unlock()
+	for _, ri := range r.Routes() {
+		coverage.routes[ri.Method+" "+ri.Path] = true
+	}
+}
+
+func markCovered(method, path string) {
+	path, _, _ = strings.Cut(path, "?")
+	coverage.Lock()
+	defer coverage.Unlock()
+	for route := range coverage.routes {
+		m, pattern, _ := strings.Cut(route, " ")
+		if m == method && routeMatches(pattern, path) {
+			coverage.covered[route] = true
+		}
+	}
+}
+
+// routeMatches compares segment by segment; :params match any segment.
+// Static segments take precedence in gin, so /posts/search does not count
+// towards /posts/:id.
+func routeMatches(pattern, path string) bool {
+	ps, xs := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(ps) != len(xs) {
+		return false
+	}
+	for i := range ps {
+		if strings.HasPrefix(ps[i], ":") {
+			if staticSibling(ps[:i], xs[i]) {
+				return false
+			}
+			continue
+		}
+		if ps[i] != xs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func staticSibling(prefix []string, segment string) bool {
+	p := strings.Join(prefix, "/") + "/" + segment
+	for route := range coverage.routes {
+		_, pattern, _ := strings.Cut(route, " ")
+		if pattern == p || strings.HasPrefix(pattern, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func uncoveredRoutes() []string {
+	coverage.Lock()
+	defer coverage.Unlock()
+	var out []string
+	for route := range coverage.routes {
+		if !coverage.covered[route] {
+			out = append(out, route)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/blog-service/internal/activity"
+	"github.com/example/blog-service/internal/logging"
+	"github.com/example/blog-service/internal/metrics"
+	"github.com/example/blog-service/internal/service"
+)
+
+// maxRequestIDLen matches activity_logs.request_id.
+const maxRequestIDLen = 100
+
+// requestLog keeps the caller's X-Request-ID, or assigns one, echoes it in
+// the response and puts it in the request context, then logs the request
+// once it is served. 5xx responses are logged as errors and 4xx as
+// warnings.
+func requestLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		c.Header("X-Request-ID", id)
+		ctx := logging.WithRequestID(c.Request.Context(), id)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		case c.FullPath() == "/healthz" || c.FullPath() == "/readyz":
+			// probes hit these every few seconds
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route(c)),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// recordMetrics counts requests and their latency per route template.
+func recordMetrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := m.RequestStarted(c.Request.Method)
+		c.Next()
+		done(route(c), c.Writer.Status())
+	}
+}
+
+// route is the template of the matched route, so paths with IDs share a
+// log field value and metric label.
+func route(c *gin.Context) string {
+	if r := c.FullPath(); r != "" {
+		return r
+	}
+	return "unmatched"
+}
+
+// validRequestID accepts IDs of printable ASCII up to maxRequestIDLen, so a
+// caller can't inject line breaks or huge values into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// actorContext records who is calling so writes can be attributed in the
+// activity log. There is no authentication here; the gateway in front of
+// the service is expected to set X-Actor-ID.
+func actorContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := activity.WithActor(c.Request.Context(), activity.Actor{
+			ID:        c.GetHeader("X-Actor-ID"),
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			RequestID: logging.RequestID(c.Request.Context()),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// requireAdmin rejects writes unless X-Actor-ID names a registered admin.
+// Reads stay open.
+func requireAdmin(admins *service.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		id := c.GetHeader("X-Actor-ID")
+		if id == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "X-Actor-ID is required"})
+			return
+		}
+		ok, err := admins.IsAdmin(c.Request.Context(), id)
+		switch {
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "admin lookup failed"})
+		case !ok:
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "actor is not an admin"})
+		default:
+			c.Next()
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/example/blog-service/internal/config"
+	"github.com/example/blog-service/internal/logging"
+	"github.com/example/blog-service/internal/models"
+)
+
+// syncBuffer is a bytes.Buffer safe for the logger and the test to share.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the logged JSON records with msg.
+func (b *syncBuffer) records(t *testing.T, msg string) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if rec["msg"] == msg {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// captureLogs makes the default logger write JSON to the returned buffer
+// until the test ends.
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	l, err := logging.New(&config.Config{LogLevel: "info", LogFormat: "json"}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(l)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return buf
+}
+
+func TestRequestLog(t *testing.T) {
+	logs := captureLogs(t)
+	h := newHarness(t)
+	h.seed()
+
+	rec := h.serve("GET", "/posts/1", "", map[string]string{"X-Request-ID": "req-123"})
+	if got := rec.Header().Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("propagated X-Request-ID = %q", got)
+	}
+
+	rec = h.serve("PUT", "/posts/1", `{"title":"Hello Again","content":"edited"}`, map[string]string{"X-Request-ID": "not valid"})
+	assigned := rec.Header().Get("X-Request-ID")
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(assigned) {
+		t.Errorf("assigned X-Request-ID = %q", assigned)
+	}
+	activity := h.posts.Activity()
+	if last := activity[len(activity)-1]; last.RequestID != assigned {
+		t.Errorf("activity request_id = %q, want %q", last.RequestID, assigned)
+	}
+
+	h.serve("GET", "/nowhere", "", nil)
+
+	var got []string
+	for _, r := range logs.records(t, "request") {
+		if r["method"] == "GET" && r["path"] == "/posts/1" {
+			if r["request_id"] != "req-123" || r["bytes"].(float64) <= 0 {
+				t.Errorf("GET record = %v; want request_id req-123 and the body size", r)
+			}
+		}
+		if _, ok := r["latency_ms"].(float64); !ok {
+			t.Errorf("record without latency_ms: %v", r)
+		}
+		got = append(got, r["level"].(string)+" "+r["method"].(string)+" "+r["route"].(string)+" "+jsonNumber(r["status"]))
+	}
+	want := []string{
+		"INFO POST /webhooks 201",
+		"INFO POST /posts 201",
+		"INFO POST /posts 201",
+		"INFO GET /posts/:id 200",
+		"INFO PUT /posts/:id 200",
+		"WARN GET unmatched 404",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("request records:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		// httptest requests come from 192.0.2.1
+		{name: "forwarded header ignored by default", want: "192.0.2.1"},
+		{name: "trusted proxy", trusted: []string{"192.0.2.0/24"}, want: "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, func(cfg *config.Config) { cfg.TrustedProxies = tt.trusted })
+			h.seed()
+			h.serve("PUT", "/posts/1", `{"title":"Hello Again","content":"edited"}`, map[string]string{"X-Forwarded-For": "203.0.113.9"})
+			activity := h.posts.Activity()
+			if got := activity[len(activity)-1].IP; got != tt.want {
+				t.Errorf("activity ip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		actor      string
+		failLookup bool
+		wantStatus int
+	}{
+		{name: "reads stay open", method: "GET", path: "/posts/search-by-tag?tag=go", wantStatus: http.StatusOK},
+		{name: "write without actor", method: "POST", path: "/posts", body: `{"title":"t","content":"x"}`, wantStatus: http.StatusUnauthorized},
+		{name: "write by unknown actor", method: "DELETE", path: "/posts/1", actor: "mallory", wantStatus: http.StatusForbidden},
+		{name: "write by admin", method: "POST", path: "/posts", body: `{"title":"t","content":"x"}`, actor: "ann", wantStatus: http.StatusCreated},
+		{name: "admin lookup fails", method: "POST", path: "/webhooks", body: `{}`, actor: "ann", failLookup: true, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, func(cfg *config.Config) { cfg.RequireAdminActor = true })
+			if err := h.admins.Create(context.Background(), &models.Admin{ID: "ann"}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.failLookup {
+				h.admins.FailOn("Exists", errors.New("db down"))
+			}
+			var headers map[string]string
+			if tt.actor != "" {
+				headers = map[string]string{"X-Actor-ID": tt.actor}
+			}
+			if rec := h.serve(tt.method, tt.path, tt.body, headers); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d %s, want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func jsonNumber(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+// until the test ends.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return exp
+}
+
+func TestTracing(t *testing.T) {
+	spans := recordSpans(t)
+	h := newHarness(t)
+	h.seed()
+	h.es.respond("POST", "/posts/_search", "must_not", http.StatusOK, esHits(map[string]interface{}{"id": 2, "title": "Secret Draft"}))
+	spans.Reset()
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	rec := h.serve("GET", "/posts/1?include_related=true", "", map[string]string{"traceparent": "00-" + traceID + "-" + parentID + "-01"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %s", rec.Code, rec.Body)
+	}
+
+	var server, es *tracetest.SpanStub
+	got := spans.GetSpans()
+	for i := range got {
+		switch s := &got[i]; {
+		case s.SpanKind == trace.SpanKindServer:
+			server = s
+		case s.Name == "elasticsearch search":
+			es = s
+		}
+	}
+	if server == nil || es == nil {
+		t.Fatalf("spans = %v; want a server span and an elasticsearch search span", got)
+	}
+	if server.SpanContext.TraceID().String() != traceID || server.Parent.SpanID().String() != parentID {
+		t.Errorf("server span trace %s parent %s; want the incoming traceparent", server.SpanContext.TraceID(), server.Parent.SpanID())
+	}
+	if es.SpanContext.TraceID().String() != traceID || es.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("elasticsearch span is not a child of the server span")
+	}
+	if es.SpanKind != trace.SpanKindClient {
+		t.Errorf("elasticsearch span kind = %v", es.SpanKind)
+	}
+
+	reqs := h.es.received("POST", "/posts/_search")
+	if len(reqs) == 0 || !strings.Contains(reqs[len(reqs)-1].Header.Get("traceparent"), traceID) {
+		t.Errorf("trace context not propagated to Elasticsearch")
+	}
+}
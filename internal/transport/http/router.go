@@ -1,31 +1,150 @@
 package http
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"github.com/example/blog-service/internal/cache"
 	"github.com/example/blog-service/internal/config"
 	"github.com/example/blog-service/internal/db"
+	"github.com/example/blog-service/internal/feed"
+	"github.com/example/blog-service/internal/health"
+	"github.com/example/blog-service/internal/metrics"
+	"github.com/example/blog-service/internal/repository"
 	"github.com/example/blog-service/internal/search"
+	"github.com/example/blog-service/internal/service"
+	"github.com/example/blog-service/internal/storage"
 	"github.com/example/blog-service/internal/transport/http/handlers"
 )
 
 type Router = *gin.Engine
 
-func NewRouter(cfg *config.Config, database *db.Database, cache *cache.RedisClient, es *search.Elastic) Router {
+// Services are what the router serves.
+type Services struct {
+	Cache    cache.Cache
+	Posts    *service.PostService
+	Media    *service.MediaService
+	Feeds    *service.FeedService
+	Sitemaps *service.SitemapService
+	Webhooks *service.WebhookService
+	Activity *service.ActivityService
+	Stream   *service.ActivityStream
+	Admins   *service.AdminService
+	Metrics  *metrics.Metrics
+	Health   *health.Checker
+}
+
+// NewServices wires the services to Postgres, the cache, Elasticsearch and
+// media storage, and exports pool and cache stats through m.
+func NewServices(cfg *config.Config, database *db.Database, cache cache.Client, es *search.Elastic, store storage.Storage, m *metrics.Metrics) Services {
+	m.RegisterDB(database.SQL, "blog")
+	for i, r := range database.Replicas {
+		if sqlDB, err := r.DB(); err == nil {
+			m.RegisterDB(sqlDB, fmt.Sprintf("blog_replica_%d", i+1))
+		}
+	}
+	m.RegisterCache(cache)
+	media := service.NewMediaService(cfg, service.NewMediaDeps(database, store))
+	stream := service.NewActivityStream(cache)
+	posts := service.NewPostDeps(cfg, database, cache, es, media)
+	posts.Events = stream
+	posts.Metrics = m
+	cacheCheck := "redis"
+	if cfg.CacheBackend == "memory" {
+		cacheCheck = "cache"
+	}
+	checker := health.NewChecker(time.Duration(cfg.HealthCheckTimeoutMs)*time.Millisecond,
+		health.Check{Name: "postgres", Run: database.SQL.PingContext},
+		health.Check{Name: cacheCheck, Run: cache.Ping},
+		health.Check{Name: "elasticsearch", Run: es.Ping},
+	)
+	return Services{
+		Cache:    cache,
+		Posts:    service.NewPostService(posts),
+		Media:    media,
+		Feeds:    service.NewFeedService(cfg, repository.NewPostRepository(database.Gorm, database.Reader), cache),
+		Sitemaps: service.NewSitemapService(cfg, repository.NewSitemapRepository(database.Gorm), cache),
+		Webhooks: service.NewWebhookService(repository.NewWebhookRepository(database.Gorm)),
+		Activity: service.NewActivityService(repository.NewActivityRepository(database.Reader)),
+		Stream:   stream,
+		Admins:   service.NewAdminService(repository.NewAdminRepository(database.Gorm)),
+		Metrics:  m,
+		Health:   checker,
+	}
+}
+
+// NewRouter fails only if cfg.TrustedProxies holds something that isn't an
+// IP or CIDR, which config.Load rejects.
+func NewRouter(cfg *config.Config, svc Services) (Router, error) {
 	if mode := gin.Mode(); mode == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	r := gin.New()
+	// gin believes X-Forwarded-For from anyone until told otherwise, which
+	// would let clients choose the IP the activity log records
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	r.Use(otelgin.Middleware("blog-service"))
+	r.Use(requestLog())
+	r.Use(recordMetrics(svc.Metrics))
 	r.Use(gin.Recovery())
+	r.Use(actorContext())
+	if cfg.RequireAdminActor {
+		r.Use(requireAdmin(svc.Admins))
+	}
 
-	h := handlers.NewPostHandler(database, cache, es)
+	h := handlers.NewPostHandler(svc.Posts)
+	mh := handlers.NewMediaHandler(svc.Media)
+	fh := handlers.NewFeedHandler(svc.Feeds)
+	sh := handlers.NewSitemapHandler(svc.Sitemaps)
+	wh := handlers.NewWebhookHandler(svc.Webhooks)
+	ah := handlers.NewActivityHandler(svc.Activity, svc.Stream)
 
 	r.POST("/posts", h.CreatePost)
 	r.GET("/posts/:id", h.GetPost)
+	r.GET("/posts/by-slug/:slug", h.GetPostBySlug)
 	r.PUT("/posts/:id", h.UpdatePost)
+	r.DELETE("/posts/:id", h.DeletePost)
 	r.GET("/posts/search-by-tag", h.SearchByTag)
 	r.GET("/posts/search", h.Search)
 
-	return r
+	r.POST("/media", mh.Upload)
+	r.GET("/media/:id", mh.Get)
+	r.GET("/media/:id/file", mh.Serve)
+	r.GET("/media/:id/renditions/:file", mh.ServeRendition)
+	r.GET("/posts/:id/media", mh.ListForPost)
+	r.PUT("/posts/:id/media/:media_id", mh.Attach)
+	r.DELETE("/posts/:id/media/:media_id", mh.Detach)
+
+	for _, prefix := range []string{"", "/tags/:tag", "/authors/:author"} {
+		r.GET(prefix+"/feed.rss", fh.Serve(feed.FormatRSS))
+		r.GET(prefix+"/feed.atom", fh.Serve(feed.FormatAtom))
+		r.GET(prefix+"/feed.json", fh.Serve(feed.FormatJSON))
+	}
+
+	r.GET("/sitemap.xml", sh.Index)
+	r.GET("/sitemaps/:file", sh.Chunk)
+
+	r.GET("/debug/cache", handlers.CacheStats(svc.Cache))
+	r.GET("/metrics", gin.WrapH(svc.Metrics.Handler()))
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz(svc.Health))
+
+	r.GET("/activity", ah.List)
+	r.GET("/activity/stream", ah.Stream)
+
+	r.POST("/webhooks", wh.Create)
+	r.GET("/webhooks", wh.List)
+	r.GET("/webhooks/:id", wh.Get)
+	r.PUT("/webhooks/:id", wh.Update)
+	r.DELETE("/webhooks/:id", wh.Delete)
+	r.GET("/webhooks/:id/deliveries", wh.Deliveries)
+	r.GET("/webhooks/:id/deliveries/:delivery_id", wh.Delivery)
+	r.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", wh.Redeliver)
+
+	return r, nil
 } 
\ No newline at end of file
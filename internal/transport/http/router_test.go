@@ -0,0 +1,714 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/blog-service/internal/cache"
+	"github.com/example/blog-service/internal/models"
+	"github.com/example/blog-service/internal/service"
+)
+
+func wantHeader(name, value string) func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestPostRoutes(t *testing.T) {
+	runRoutes(t, []routeCase{
+		{
+			name: "create", method: "POST", path: "/posts",
+			body:       `{"title":"Third Post","content":"# Heading\n\nbody","content_format":"markdown","tags":["go"]}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   []string{`"slug":"third-post"`, `\u003ch1 id=\"heading\"`},
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				if got := h.es.received("PUT", "/posts/_doc/3"); len(got) != 1 {
+					t.Errorf("indexed %d times, want 1", len(got))
+				}
+			},
+		},
+		{
+			name: "create without title", method: "POST", path: "/posts",
+			body:       `{"content":"x"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'Title' failed on the 'required' tag`},
+		},
+		{
+			name: "create with unknown format", method: "POST", path: "/posts",
+			body:       `{"title":"t","content":"x","content_format":"rtf"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'ContentFormat' failed on the 'oneof' tag`},
+		},
+		{
+			name: "create with long slug", method: "POST", path: "/posts",
+			body:       `{"title":"t","content":"x","slug":"` + strings.Repeat("s", 256) + `"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'Slug' failed on the 'max' tag`},
+		},
+		{
+			name: "create with long author", method: "POST", path: "/posts",
+			body:       `{"title":"t","content":"x","author":"` + strings.Repeat("a", 101) + `"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'Author' failed on the 'max' tag`},
+		},
+		{
+			name: "create with zero cover", method: "POST", path: "/posts",
+			body:       `{"title":"t","content":"x","cover_media_id":0}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'CoverMediaID' failed on the 'min' tag`},
+		},
+		{
+			name: "create with unknown cover", method: "POST", path: "/posts",
+			body:       `{"title":"t","content":"x","cover_media_id":99}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"cover media not found"},
+		},
+		{
+			name: "create with malformed json", method: "POST", path: "/posts",
+			body:       `{"title":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "create with wrong types", method: "POST", path: "/posts",
+			body:       `{"title":"t","content":"x","tags":"go"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "get", method: "GET", path: "/posts/1",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"title":"Hello World"`},
+			notBody:    []string{"related_posts"},
+		},
+		{
+			name: "get with related", method: "GET", path: "/posts/1?include_related=true",
+			setup: func(h *harness) {
+				h.es.respond("POST", "/posts/_search", "must_not", http.StatusOK, esHits(map[string]interface{}{"id": 7, "title": "Go Tips"}))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"related_posts":[{`, `"title":"Go Tips"`},
+		},
+		{
+			name: "get with related while search is down", method: "GET", path: "/posts/1?include_related=true",
+			setup: func(h *harness) {
+				h.es.respond("POST", "/posts/_search", "", http.StatusServiceUnavailable, `{}`)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"related_posts":[]`},
+		},
+		{name: "get missing", method: "GET", path: "/posts/99", wantStatus: http.StatusNotFound},
+		{name: "get with bad id", method: "GET", path: "/posts/abc", wantStatus: http.StatusBadRequest, wantBody: []string{"invalid id"}},
+		{
+			name: "get by slug", method: "GET", path: "/posts/by-slug/hello-world",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"id":1`},
+		},
+		{
+			name: "get by old slug", method: "GET", path: "/posts/by-slug/hello-world?include_related=true",
+			setup: func(h *harness) {
+				h.mustDo("PUT", "/posts/1", `{"title":"Hello World","slug":"hello-again","content":"first post"}`, http.StatusOK)
+			},
+			wantStatus: http.StatusMovedPermanently,
+			check:      wantHeader("Location", "/posts/by-slug/hello-again?include_related=true"),
+		},
+		{
+			name: "get by slug with related", method: "GET", path: "/posts/by-slug/hello-world?include_related=true",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"slug":"hello-world"`, `"related_posts":`},
+		},
+		{name: "get by unknown slug", method: "GET", path: "/posts/by-slug/nope", wantStatus: http.StatusNotFound},
+		{
+			name: "update", method: "PUT", path: "/posts/1",
+			body:       `{"title":"Hello Again","content":"edited"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"title":"Hello Again"`, `"author":"ann"`},
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				if got := h.es.received("PUT", "/posts/_doc/1"); len(got) != 2 {
+					t.Errorf("indexed %d times, want 2", len(got))
+				}
+			},
+		},
+		{
+			name: "publish draft", method: "PUT", path: "/posts/2",
+			body:       `{"title":"Secret Draft","content":"done","status":"published"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"status":"published"`, `"published_at":"`},
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				var actions []string
+				for _, e := range h.posts.Activity() {
+					actions = append(actions, e.Action)
+				}
+				if !strings.Contains(strings.Join(actions, ","), "publish_post") {
+					t.Errorf("actions = %v, want publish_post", actions)
+				}
+			},
+		},
+		{
+			name: "update without content", method: "PUT", path: "/posts/1",
+			body:       `{"title":"t"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'Content' failed on the 'required' tag`},
+		},
+		{
+			name: "update with unknown status", method: "PUT", path: "/posts/1",
+			body:       `{"title":"t","content":"x","status":"archived"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'Status' failed on the 'oneof' tag`},
+		},
+		{
+			name: "update replacing and removing the cover", method: "PUT", path: "/posts/1",
+			body:       `{"title":"t","content":"x","cover_media_id":1,"remove_cover":true}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{`'RemoveCover' failed on the 'excluded_with' tag`},
+		},
+		{name: "update missing", method: "PUT", path: "/posts/99", body: `{"title":"t","content":"x"}`, wantStatus: http.StatusNotFound},
+		{name: "update with bad id", method: "PUT", path: "/posts/0", body: `{"title":"t","content":"x"}`, wantStatus: http.StatusBadRequest},
+		{
+			name: "delete", method: "DELETE", path: "/posts/1",
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				if got := h.es.received("DELETE", "/posts/_doc/1"); len(got) != 1 {
+					t.Errorf("deleted from index %d times, want 1", len(got))
+				}
+				if rec := h.serve("GET", "/posts/1", "", nil); rec.Code != http.StatusNotFound {
+					t.Errorf("GET after delete: status %d", rec.Code)
+				}
+			},
+		},
+		{name: "delete missing", method: "DELETE", path: "/posts/99", wantStatus: http.StatusNotFound},
+		{name: "delete with bad id", method: "DELETE", path: "/posts/x", wantStatus: http.StatusBadRequest, wantBody: []string{"invalid id"}},
+		{
+			name: "search by tag", method: "GET", path: "/posts/search-by-tag?tag=db",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"Hello World"},
+			notBody:    []string{"Secret Draft"},
+		},
+		{name: "search by tag without tag", method: "GET", path: "/posts/search-by-tag", wantStatus: http.StatusBadRequest, wantBody: []string{"tag is required"}},
+		{
+			name: "search", method: "GET", path: "/posts/search?q=Hello",
+			setup: func(h *harness) {
+				h.es.respond("POST", "/posts/_search", "multi_match", http.StatusOK, esHits(map[string]interface{}{"id": 1, "title": "Hello World"}))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"title":"Hello World"`},
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				reqs := h.es.received("POST", "/posts/_search")
+				if len(reqs) != 1 || !strings.Contains(reqs[0].Body, `"query":"hello"`) {
+					t.Errorf("search requests = %+v", reqs)
+				}
+				// documents indexed before posts had a status still match
+				if len(reqs) == 1 && !strings.Contains(reqs[0].Body, `{"must_not":{"exists":{"field":"status"}}}`) {
+					t.Errorf("search filter drops documents without a status: %s", reqs[0].Body)
+				}
+			},
+		},
+		{name: "search without query", method: "GET", path: "/posts/search", wantStatus: http.StatusBadRequest, wantBody: []string{"q is required"}},
+		{
+			name: "search while search is down", method: "GET", path: "/posts/search?q=x",
+			setup: func(h *harness) {
+				h.es.respond("POST", "/posts/_search", "", http.StatusInternalServerError, `{"error":"boom"}`)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	})
+}
+
+// pngBytes passes content sniffing as image/png.
+func pngBytes(size int) []byte {
+	b := make([]byte, size)
+	copy(b, "\x89PNG\r\n\x1a\n")
+	return b
+}
+
+// seedMedia stores ready media 1 with a stripped original and a thumbnail,
+// attached to post 1, pending media 2 and failed media 3.
+func seedMedia(h *harness) {
+	h.t.Helper()
+	ctx := context.Background()
+	for key, data := range map[string]string{"orig.png": "upload", "stripped.png": "original", "thumb.webp": "thumbnail"} {
+		if err := h.store.Put(ctx, key, strings.NewReader(data), int64(len(data)), ""); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	id := h.media.Add(models.Media{
+		Hash: "abc", StorageKey: "orig.png", MimeType: "image/png", Size: 8, OriginalName: "orig.png",
+		Renditions: []models.MediaRendition{
+			{Name: "original", Format: "png", StorageKey: "stripped.png", MimeType: "image/png", Hash: "abd"},
+			{Name: "thumbnail", Format: "webp", StorageKey: "thumb.webp", MimeType: "image/webp", Hash: "def"},
+		},
+	})
+	h.media.Add(models.Media{Hash: "ghi", StorageKey: "missing.png", MimeType: "image/png", Status: models.MediaPending})
+	h.media.Add(models.Media{Hash: "jkl", StorageKey: "broken.png", MimeType: "image/png", Status: models.MediaFailed})
+	if err := h.media.Link(ctx, nil, 1, id); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+func TestMediaRoutes(t *testing.T) {
+	runRoutes(t, []routeCase{
+		{name: "get", setup: seedMedia, method: "GET", path: "/media/1", wantStatus: http.StatusOK, wantBody: []string{`"status":"ready"`, `"url":"`}},
+		{name: "get missing", setup: seedMedia, method: "GET", path: "/media/99", wantStatus: http.StatusNotFound},
+		{name: "get with bad id", setup: seedMedia, method: "GET", path: "/media/x", wantStatus: http.StatusBadRequest},
+		{
+			name: "file", setup: seedMedia, method: "GET", path: "/media/1/file",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"original"},
+			check:      wantHeader("ETag", `"abd"`),
+		},
+		{
+			name: "file not modified", setup: seedMedia, method: "GET", path: "/media/1/file",
+			header:     map[string]string{"If-None-Match": `"abd"`},
+			wantStatus: http.StatusNotModified,
+		},
+		{name: "file still processing", setup: seedMedia, method: "GET", path: "/media/2/file", wantStatus: http.StatusConflict},
+		{name: "file failed processing", setup: seedMedia, method: "GET", path: "/media/3/file", wantStatus: http.StatusUnprocessableEntity, wantBody: []string{"could not be processed"}},
+		{name: "file missing", setup: seedMedia, method: "GET", path: "/media/99/file", wantStatus: http.StatusNotFound},
+		{
+			name: "rendition", setup: seedMedia, method: "GET", path: "/media/1/renditions/thumbnail.webp",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"thumbnail"},
+			check:      wantHeader("Content-Type", "image/webp"),
+		},
+		{name: "rendition missing", setup: seedMedia, method: "GET", path: "/media/1/renditions/large.jpg", wantStatus: http.StatusNotFound},
+		{name: "list for post", setup: seedMedia, method: "GET", path: "/posts/1/media", wantStatus: http.StatusOK, wantBody: []string{`"id":1`}},
+		{name: "list for post without media", setup: seedMedia, method: "GET", path: "/posts/2/media", wantStatus: http.StatusOK, wantBody: []string{`[]`}},
+		{
+			name: "attach", setup: seedMedia, method: "PUT", path: "/posts/2/media/1",
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				if !h.media.Linked(2, 1) {
+					t.Error("media 1 not linked to post 2")
+				}
+			},
+		},
+		{name: "attach to missing post", setup: seedMedia, method: "PUT", path: "/posts/99/media/1", wantStatus: http.StatusNotFound},
+		{name: "attach missing media", setup: seedMedia, method: "PUT", path: "/posts/1/media/99", wantStatus: http.StatusNotFound},
+		{name: "attach with bad media id", setup: seedMedia, method: "PUT", path: "/posts/1/media/x", wantStatus: http.StatusBadRequest, wantBody: []string{"invalid media_id"}},
+		{
+			name: "detach", setup: seedMedia, method: "DELETE", path: "/posts/1/media/1",
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				if h.media.Linked(1, 1) {
+					t.Error("media 1 still linked to post 1")
+				}
+				if rec := h.serve("GET", "/media/1", "", nil); rec.Code != http.StatusNotFound {
+					t.Errorf("orphaned media not collected: status %d", rec.Code)
+				}
+			},
+		},
+		{name: "detach with bad id", setup: seedMedia, method: "DELETE", path: "/posts/x/media/1", wantStatus: http.StatusBadRequest},
+	})
+}
+
+func TestMediaUpload(t *testing.T) {
+	cases := []struct {
+		name       string
+		data       []byte
+		fields     map[string]string
+		wantStatus int
+		wantBody   string
+		linked     bool
+	}{
+		{name: "png", data: pngBytes(100), wantStatus: http.StatusCreated, wantBody: `"status":"pending"`},
+		{name: "linked to post", data: pngBytes(100), fields: map[string]string{"post_id": "1"}, wantStatus: http.StatusCreated, linked: true},
+		{name: "missing post", data: pngBytes(100), fields: map[string]string{"post_id": "99"}, wantStatus: http.StatusNotFound},
+		{name: "bad post id", data: pngBytes(100), fields: map[string]string{"post_id": "x"}, wantStatus: http.StatusBadRequest, wantBody: "invalid post_id"},
+		{name: "no file", wantStatus: http.StatusBadRequest, wantBody: "file is required"},
+		{name: "unsupported type", data: []byte("plain text"), wantStatus: http.StatusUnsupportedMediaType, wantBody: "text/plain"},
+		{name: "over the limit", data: pngBytes(5 << 10), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "over the body limit", data: pngBytes(2 << 20), wantStatus: http.StatusRequestEntityTooLarge, wantBody: "file too large"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t)
+			h.seed()
+			rec := h.upload("a.png", tc.data, tc.fields)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Errorf("body lacks %q: %s", tc.wantBody, rec.Body)
+			}
+			if tc.linked && !h.media.Linked(1, 1) {
+				t.Error("upload not linked to post 1")
+			}
+		})
+	}
+}
+
+func TestFeedRoutes(t *testing.T) {
+	types := map[string]string{
+		"rss":  "application/rss+xml; charset=utf-8",
+		"atom": "application/atom+xml; charset=utf-8",
+		"json": "application/feed+json; charset=utf-8",
+	}
+	var cases []routeCase
+	for _, prefix := range []string{"", "/tags/go", "/authors/ann"} {
+		for ext, ct := range types {
+			cases = append(cases, routeCase{
+				name: prefix + "/feed." + ext, method: "GET", path: prefix + "/feed." + ext,
+				wantStatus: http.StatusOK,
+				wantBody:   []string{"Hello World"},
+				notBody:    []string{"Secret Draft"},
+				check:      wantHeader("Content-Type", ct),
+			})
+		}
+	}
+	cases = append(cases,
+		routeCase{
+			name: "unknown author", method: "GET", path: "/authors/bob/feed.json",
+			wantStatus: http.StatusOK,
+			notBody:    []string{"Hello World"},
+		},
+	)
+	runRoutes(t, cases)
+}
+
+func TestFeedNotModified(t *testing.T) {
+	h := newHarness(t)
+	h.seed()
+	etag := h.do("GET", "/feed.atom", "", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if rec := h.do("GET", "/feed.atom", "", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want 304", rec.Code)
+	}
+	h.mustDo("PUT", "/posts/1", `{"title":"Hello World","content":"edited"}`, http.StatusOK)
+	if rec := h.do("GET", "/feed.atom", "", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusOK {
+		t.Errorf("after an edit: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestSitemapRoutes(t *testing.T) {
+	runRoutes(t, []routeCase{
+		{
+			name: "index", method: "GET", path: "/sitemap.xml",
+			wantStatus: http.StatusOK,
+			wantBody: []string{
+				"http://blog.test/sitemaps/posts-1.xml",
+				"http://blog.test/sitemaps/tags-1.xml",
+				"http://blog.test/sitemaps/authors-1.xml",
+			},
+		},
+		{
+			name: "posts", method: "GET", path: "/sitemaps/posts-1.xml",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"hello-world"},
+			notBody:    []string{"secret-draft"},
+		},
+		{
+			name: "tags", method: "GET", path: "/sitemaps/tags-1.xml",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"http://blog.test/tags/db/feed.rss", "http://blog.test/tags/go/feed.rss"},
+		},
+		{
+			name: "authors", method: "GET", path: "/sitemaps/authors-1.xml",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"http://blog.test/authors/ann/feed.rss"},
+		},
+		{name: "chunk out of range", method: "GET", path: "/sitemaps/posts-2.xml", wantStatus: http.StatusNotFound},
+		{name: "unknown kind", method: "GET", path: "/sitemaps/pages-1.xml", wantStatus: http.StatusNotFound},
+		{name: "no chunk number", method: "GET", path: "/sitemaps/posts.xml", wantStatus: http.StatusNotFound},
+	})
+}
+
+func TestCacheStatsRoute(t *testing.T) {
+	h := newHarness(t)
+	h.seed()
+	before := h.cache.Stats()
+	h.mustDo("GET", "/posts/1", "", http.StatusOK)
+	h.mustDo("GET", "/posts/1", "", http.StatusOK)
+
+	rec := h.do("GET", "/debug/cache", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var got cache.Stats
+	decode(t, rec, &got)
+	if got.Hits-before.Hits != 1 || got.Misses-before.Misses != 1 {
+		t.Errorf("stats = %+v, before %+v; want one more hit and miss", got, before)
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	h := newHarness(t)
+	h.seed()
+	h.mustDo("GET", "/posts/1", "", http.StatusOK)
+	h.mustDo("GET", "/posts/1", "", http.StatusOK)
+	h.mustDo("GET", "/posts/999", "", http.StatusNotFound)
+	h.mustDo("PUT", "/posts/1", `{"title":"Hello Again","content":"edited"}`, http.StatusOK)
+	h.mustDo("GET", "/posts/search-by-tag?tag=go", "", http.StatusOK)
+	h.mustDo("GET", "/posts/search-by-tag?tag=rust", "", http.StatusOK)
+	h.mustDo("GET", "/posts/search?q=nothing", "", http.StatusOK)
+	h.es.respond("POST", "/posts/_search", "", http.StatusInternalServerError, `{"error":"boom"}`)
+	h.mustDo("GET", "/posts/search?q=broken", "", http.StatusInternalServerError)
+	h.serve("GET", "/nowhere", "", nil)
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"blog_http_requests_total", []string{"method", "GET", "route", "/posts/:id", "status", "200"}, 2},
+		{"blog_http_requests_total", []string{"method", "GET", "route", "/posts/:id", "status", "404"}, 1},
+		{"blog_http_requests_total", []string{"route", "/posts/search", "status", "500"}, 1},
+		{"blog_http_request_duration_seconds", []string{"method", "GET", "route", "/posts/:id"}, 3},
+		{"blog_http_requests_in_flight", nil, 0},
+		{"blog_posts_writes_total", []string{"action", "create"}, 2},
+		{"blog_posts_writes_total", []string{"action", "update"}, 1},
+		{"blog_posts_writes_total", []string{"action", "delete"}, 0},
+		{"blog_search_queries_total", []string{"kind", "tag"}, 2},
+		// the update dropped post 1's tags and the draft doesn't count
+		{"blog_search_zero_results_total", []string{"kind", "tag"}, 2},
+		{"blog_search_queries_total", []string{"kind", "fulltext"}, 1},
+		{"blog_search_zero_results_total", []string{"kind", "fulltext"}, 1},
+		{"blog_elasticsearch_requests_total", []string{"operation", "index", "result", "ok"}, 3},
+		{"blog_elasticsearch_requests_total", []string{"operation", "search", "result", "error"}, 1},
+		{"blog_elasticsearch_request_duration_seconds", []string{"operation", "search"}, 2},
+		{"blog_cache_fetches_total", []string{"result", "hit"}, 1},
+		{"blog_cache_fetches_total", []string{"result", "negative_hit"}, 0},
+	}
+	for _, tt := range tests {
+		if got := h.metric(tt.name, tt.labels...); got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	rec := h.do("GET", "/metrics", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	for _, want := range []string{
+		`blog_http_requests_total{method="GET",route="/posts/:id",status="200"} 2`,
+		`blog_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		"# TYPE blog_cache_fetches_total counter",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("/metrics lacks %q", want)
+		}
+	}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	runRoutes(t, []routeCase{
+		{name: "alive", method: "GET", path: "/healthz", wantStatus: http.StatusOK, wantBody: []string{`{"status":"ok"}`}},
+		{
+			name: "ready", method: "GET", path: "/readyz",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"status":"ok","checks":{`, `"postgres":{"status":"ok","latency_ms":`, `"cache":{"status":"ok"`, `"elasticsearch":{"status":"ok"`},
+			check:      wantHeader("Cache-Control", "no-store"),
+		},
+		{
+			name: "search down", method: "GET", path: "/readyz",
+			setup: func(h *harness) {
+				h.es.respond("HEAD", "/", "", http.StatusServiceUnavailable, "")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   []string{`"status":"failing"`, `"elasticsearch":{"status":"failing"`, `"postgres":{"status":"ok"`},
+		},
+		{
+			name: "postgres hangs past its timeout", method: "GET", path: "/readyz",
+			setup: func(h *harness) {
+				h.pgPing = func(context.Context) error { select {} }
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   []string{`"postgres":{"status":"failing"`, "context deadline exceeded", `"elasticsearch":{"status":"ok"`},
+		},
+		{
+			name: "draining", method: "GET", path: "/readyz",
+			setup: func(h *harness) {
+				h.health.Drain()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   []string{`{"status":"draining"}`},
+		},
+		{
+			name: "alive while draining", method: "GET", path: "/healthz",
+			setup: func(h *harness) {
+				h.health.Drain()
+			},
+			wantStatus: http.StatusOK,
+		},
+	})
+}
+
+func TestActivityRoutes(t *testing.T) {
+	edit := func(h *harness) {
+		h.t.Helper()
+		rec := h.serve("PUT", "/posts/1", `{"title":"Hello Again","content":"edited"}`, map[string]string{"X-Actor-ID": "editor"})
+		if rec.Code != http.StatusOK {
+			h.t.Fatalf("edit: %d %s", rec.Code, rec.Body)
+		}
+	}
+	items := func(want ...string) func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+		return func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+			var page service.ActivityPage
+			decode(t, rec, &page)
+			var got []string
+			for _, e := range page.Items {
+				got = append(got, e.Action)
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("actions = %v, want %v", got, want)
+			}
+		}
+	}
+	runRoutes(t, []routeCase{
+		{name: "all", setup: edit, method: "GET", path: "/activity", wantStatus: http.StatusOK, check: items("update_post", "new_post", "publish_post", "new_post")},
+		{name: "by action", setup: edit, method: "GET", path: "/activity?action=new_post", wantStatus: http.StatusOK, check: items("new_post", "new_post")},
+		{name: "by actor", setup: edit, method: "GET", path: "/activity?actor_id=editor", wantStatus: http.StatusOK, check: items("update_post")},
+		{name: "by post", setup: edit, method: "GET", path: "/activity?post_id=2", wantStatus: http.StatusOK, check: items("new_post")},
+		{
+			name: "paged", setup: edit, method: "GET", path: "/activity?limit=1",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"next_cursor":"`},
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				link := rec.Header().Get("Link")
+				if !strings.HasPrefix(link, "</activity?") || !strings.Contains(link, "cursor=") || !strings.HasSuffix(link, `>; rel="next"`) {
+					t.Errorf("Link = %q", link)
+				}
+			},
+		},
+		{name: "bad cursor", method: "GET", path: "/activity?cursor=zzz", wantStatus: http.StatusBadRequest, wantBody: []string{"invalid cursor"}},
+		{name: "limit too large", method: "GET", path: "/activity?limit=500", wantStatus: http.StatusBadRequest},
+		{name: "bad from", method: "GET", path: "/activity?from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "stream with bad post id", method: "GET", path: "/activity/stream?post_id=x", wantStatus: http.StatusBadRequest, wantBody: []string{"invalid post_id"}},
+		{
+			name: "stream with bad event id", method: "GET", path: "/activity/stream",
+			header:     map[string]string{"Last-Event-ID": "nope"},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid Last-Event-ID"},
+		},
+	})
+}
+
+// sseEvents connects to /activity/stream on a real server and sends the
+// event names it receives until the test ends.
+func sseEvents(t *testing.T, h *harness, query string) <-chan string {
+	t.Helper()
+	markCovered("GET", "/activity/stream")
+	srv := httptest.NewServer(h.router)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(srv.Close)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/activity/stream"+query, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, ct)
+	}
+	events := make(chan string, 16)
+	go func() {
+		defer resp.Body.Close()
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if name, ok := strings.CutPrefix(sc.Text(), "event: "); ok {
+				events <- name
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan string) string {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return ""
+	}
+}
+
+func TestActivityStreamReplay(t *testing.T) {
+	h := newHarness(t)
+	h.seed()
+	events := sseEvents(t, h, "?last_event_id=0-0&post_id=2")
+	if ev := nextEvent(t, events); ev != "new_post" {
+		t.Errorf("replayed %q, want new_post", ev)
+	}
+}
+
+func TestActivityStreamLive(t *testing.T) {
+	h := newHarness(t)
+	h.seed()
+	events := sseEvents(t, h, "?action=delete_post")
+	// the relay subscribes in the background, so keep producing events until
+	// one gets through
+	for i := 0; i < 20; i++ {
+		rec := h.mustDo("POST", "/posts", `{"title":"Temp","content":"x"}`, http.StatusCreated)
+		var p models.Post
+		decode(t, rec, &p)
+		h.mustDo("DELETE", fmt.Sprintf("/posts/%d", p.ID), "", http.StatusNoContent)
+		select {
+		case ev := <-events:
+			if ev != "delete_post" {
+				t.Errorf("got %q, want delete_post", ev)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	t.Fatal("no live event")
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	runRoutes(t, []routeCase{
+		{
+			name: "create", method: "POST", path: "/webhooks",
+			body:       `{"url":"https://example.com/hook","events":["post.deleted"],"secret":"0123456789abcdef"}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   []string{`"id":2`, `"secret":"0123456789abcdef"`},
+		},
+		{name: "create without url", method: "POST", path: "/webhooks", body: `{"events":["post.created"]}`, wantStatus: http.StatusBadRequest, wantBody: []string{`'URL' failed on the 'required' tag`}},
+		{name: "create with bad url", method: "POST", path: "/webhooks", body: `{"url":"not a url","events":["post.created"]}`, wantStatus: http.StatusBadRequest, wantBody: []string{`'URL' failed on the 'url' tag`}},
+		{name: "create without events", method: "POST", path: "/webhooks", body: `{"url":"https://example.com","events":[]}`, wantStatus: http.StatusBadRequest, wantBody: []string{`'Events' failed on the 'min' tag`}},
+		{name: "create with short secret", method: "POST", path: "/webhooks", body: `{"url":"https://example.com","events":["post.created"],"secret":"short"}`, wantStatus: http.StatusBadRequest, wantBody: []string{`'Secret' failed on the 'min' tag`}},
+		{name: "create with ftp url", method: "POST", path: "/webhooks", body: `{"url":"ftp://example.com","events":["post.created"]}`, wantStatus: http.StatusBadRequest, wantBody: []string{"absolute http(s) URL"}},
+		{name: "create with unknown event", method: "POST", path: "/webhooks", body: `{"url":"https://example.com","events":["post.liked"]}`, wantStatus: http.StatusBadRequest, wantBody: []string{`unknown event \"post.liked\"`}},
+		{name: "list", method: "GET", path: "/webhooks", wantStatus: http.StatusOK, wantBody: []string{"https://hooks.test/in"}, notBody: []string{`"secret"`}},
+		{name: "get", method: "GET", path: "/webhooks/1", wantStatus: http.StatusOK, wantBody: []string{`"events":["post.created","post.updated"]`}, notBody: []string{`"secret"`}},
+		{name: "get missing", method: "GET", path: "/webhooks/9", wantStatus: http.StatusNotFound},
+		{
+			name: "update", method: "PUT", path: "/webhooks/1",
+			body:       `{"url":"https://hooks.test/in","events":["post.deleted"],"active":false}`,
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"active":false`, `"events":["post.deleted"]`},
+		},
+		{name: "update missing", method: "PUT", path: "/webhooks/9", body: `{"url":"https://hooks.test/in","events":["post.deleted"]}`, wantStatus: http.StatusNotFound},
+		{name: "delete", method: "DELETE", path: "/webhooks/1", wantStatus: http.StatusNoContent},
+		{name: "delete missing", method: "DELETE", path: "/webhooks/9", wantStatus: http.StatusNotFound},
+		{
+			name: "deliveries", method: "GET", path: "/webhooks/1/deliveries",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, h *harness, rec *httptest.ResponseRecorder) {
+				var got []models.WebhookDelivery
+				decode(t, rec, &got)
+				if len(got) != 2 || got[0].Event != "post.created" {
+					t.Errorf("deliveries = %+v, want 2 for post.created", got)
+				}
+			},
+		},
+		{name: "deliveries by status", method: "GET", path: "/webhooks/1/deliveries?status=failed", wantStatus: http.StatusOK, wantBody: []string{`[]`}},
+		{name: "deliveries of missing webhook", method: "GET", path: "/webhooks/9/deliveries", wantStatus: http.StatusNotFound},
+		{name: "delivery", method: "GET", path: "/webhooks/1/deliveries/1", wantStatus: http.StatusOK, wantBody: []string{`"status":"pending"`}},
+		{name: "delivery missing", method: "GET", path: "/webhooks/1/deliveries/9", wantStatus: http.StatusNotFound},
+		{name: "delivery with bad id", method: "GET", path: "/webhooks/1/deliveries/x", wantStatus: http.StatusBadRequest, wantBody: []string{"invalid delivery_id"}},
+		{name: "redeliver", method: "POST", path: "/webhooks/1/deliveries/1/redeliver", wantStatus: http.StatusAccepted, wantBody: []string{`"id":3`}},
+		{name: "redeliver missing", method: "POST", path: "/webhooks/1/deliveries/9/redeliver", wantStatus: http.StatusNotFound},
+	})
+}